@@ -0,0 +1,118 @@
+package crosslab
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kanzifucius/crosslab/pkg/cache"
+	"github.com/kanzifucius/crosslab/pkg/config"
+	"github.com/kanzifucius/crosslab/pkg/provider"
+
+	"github.com/spf13/cobra"
+)
+
+var refreshImages bool
+
+func init() {
+	RootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheChartCmd)
+	cacheCmd.AddCommand(cacheImagesCmd)
+
+	// Add flags to cache chart command
+	cacheChartCmd.Flags().StringVarP(&providerConfigFile, "config", "c", ".crosslab/config/crosslab-config.yaml", "Path to the provider configuration file")
+	cacheChartCmd.Flags().StringVar(&crossplaneChart, "chart", "", "Crossplane chart name or oci:// reference (overrides the configuration)")
+	cacheChartCmd.Flags().StringVar(&crossplaneVersion, "crossplane-version", "", "Crossplane Helm chart version (overrides the configuration)")
+
+	// Add flags to cache images command
+	cacheImagesCmd.Flags().StringVarP(&providerConfigFile, "config", "c", ".crosslab/config/crosslab-config.yaml", "Path to the provider configuration file")
+	cacheImagesCmd.Flags().BoolVar(&refreshImages, "refresh", false, "Pull images again even if they are already cached")
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the crosslab cache",
+	Long:  `Pre-fetch artifacts into the crosslab cache so that later installs work offline`,
+}
+
+var cacheChartCmd = &cobra.Command{
+	Use:   "chart",
+	Short: "Cache the Crossplane Helm chart",
+	Long: `Download the Crossplane Helm chart configured in the crossplane section of the
+configuration file into the crosslab cache. Installs of a pinned version use
+the cached chart; unpinned installs fall back to the latest cached chart when
+the repository cannot be reached.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		crossplaneConfig, _, err := loadCrossplaneConfig(providerConfigFile)
+		if err != nil {
+			return err
+		}
+
+		dir, err := cache.Dir()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Caching Crossplane chart in %s...\n", dir)
+		path, err := provider.PullCrossplaneChart(crossplaneConfig)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Crossplane chart cached at %s ✓\n", path)
+		return nil
+	},
+}
+
+var cacheImagesCmd = &cobra.Command{
+	Use:   "images",
+	Short: "Cache the provider and function images",
+	Long: `Pull the images of the providers and functions in the configuration file and
+save them into the crosslab cache, so that 'cluster create --preload-images'
+can load them into the cluster nodes without pulling them again.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		if err := config.CheckConfigFile(providerConfigFile); err != nil {
+			return err
+		}
+
+		providerConfig, err := config.LoadConfig(providerConfigFile)
+		if err != nil {
+			return fmt.Errorf("failed to load provider configuration: %v", err)
+		}
+
+		if _, err := cachePackageImages(ctx, providerConfig.Packages(), refreshImages); err != nil {
+			return err
+		}
+
+		fmt.Println("All images cached ✓")
+		return nil
+	},
+}
+
+// cachePackageImages saves the images of the given providers and functions
+// into the image cache and returns the paths of their archives.
+// Configurations have no runtime, so their images are not cached.
+func cachePackageImages(ctx context.Context, packages []config.Package, refresh bool) ([]string, error) {
+	var archives []string
+	for _, p := range packages {
+		if p.Kind == config.ConfigurationKind {
+			continue
+		}
+
+		image := p.Ref()
+		if _, ok := cache.CachedImage(image); ok && !refresh {
+			fmt.Printf("%s is cached ✓\n", image)
+		} else {
+			fmt.Printf("Caching %s...\n", image)
+		}
+
+		archive, err := cache.PullImage(ctx, image, refresh)
+		if err != nil {
+			return nil, err
+		}
+		archives = append(archives, archive)
+	}
+
+	return archives, nil
+}
@@ -3,9 +3,14 @@ package crosslab
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/kanzifucius/crosslab/pkg/config"
 	"github.com/kanzifucius/crosslab/pkg/kind"
+	"github.com/kanzifucius/crosslab/pkg/kube"
 	"github.com/kanzifucius/crosslab/pkg/provider"
 
 	"github.com/spf13/cobra"
@@ -17,6 +22,23 @@ var (
 	clusterName    string
 	forceProviders bool
 	forceCreate    bool
+	installWorkers int
+	preloadImages  bool
+	localRegistry  bool
+	nodeImage      string
+	k8sVersion     string
+
+	waitForReady      time.Duration
+	retainNodes       bool
+	stopBeforeSetup   bool
+	displayUsage      bool
+	displaySalutation bool
+	kindVerbosity     int
+
+	crossplaneVersion     string
+	crossplaneChart       string
+	crossplaneValuesFiles []string
+	crossplaneSetValues   []string
 )
 
 func init() {
@@ -27,8 +49,24 @@ func init() {
 	createCmd.Flags().StringVarP(&kindConfigFile, "config", "c", ".crosslab/kind-config.yaml", "Path to the Kind cluster configuration file")
 	createCmd.Flags().StringVarP(&clusterConfig, "provider-config", "p", ".crosslab/config/crosslab-config.yaml", "Path to the provider configuration file")
 	createCmd.Flags().StringVarP(&clusterName, "name", "n", "kind", "Name of the Kind cluster")
-	createCmd.Flags().BoolVarP(&forceProviders, "force-providers", "f", false, "Force reinstall providers if they exist")
+	createCmd.Flags().BoolVarP(&forceProviders, "force-providers", "f", false, "Upgrade existing providers in place")
 	createCmd.Flags().BoolVar(&forceCreate, "force", false, "Force recreation of cluster if it exists")
+	createCmd.Flags().IntVarP(&installWorkers, "workers", "w", provider.DefaultInstallWorkers, "Maximum number of packages installed concurrently")
+	createCmd.Flags().StringVar(&nodeImage, "image", "", "Node image of every node, e.g. kindest/node:v1.31.6 (overrides the configuration)")
+	createCmd.Flags().StringVar(&k8sVersion, "k8s-version", "", "Kubernetes version of the node image, e.g. 1.31 (overrides the configuration)")
+	createCmd.Flags().DurationVar(&waitForReady, "wait", 0, "Wait for the control plane to become ready, e.g. 2m (default does not wait)")
+	createCmd.Flags().BoolVar(&retainNodes, "retain", false, "Keep the nodes when creation fails, for debugging")
+	createCmd.Flags().BoolVar(&stopBeforeSetup, "stop-before-setup", false, "Only create the node containers, without setting up Kubernetes or installing Crossplane")
+	createCmd.Flags().BoolVar(&displayUsage, "display-usage", false, "Print how to use the cluster once it is created")
+	createCmd.Flags().BoolVar(&displaySalutation, "display-salutation", false, "Print a salutation once the cluster is created")
+	createCmd.Flags().IntVar(&kindVerbosity, "verbosity", 0, "Verbosity of the kind logs")
+	createCmd.Flags().BoolVar(&localRegistry, "local-registry", false, "Start a local registry on "+kind.RegistryHost+" and wire it into the cluster")
+	createCmd.Flags().BoolVar(&useLocalStack, "localstack", false, "Deploy LocalStack and point the AWS providers at it (overrides the configuration)")
+	createCmd.Flags().BoolVar(&preloadImages, "preload-images", false, "Load cached provider and function runtime images into the cluster nodes before installing them; Crossplane still downloads the package contents from their registry")
+	createCmd.Flags().StringVar(&crossplaneVersion, "crossplane-version", "", "Crossplane Helm chart version (overrides the configuration)")
+	createCmd.Flags().StringVar(&crossplaneChart, "chart", "", "Crossplane chart: a chart name, an oci:// reference, or a local directory or .tgz (overrides the configuration)")
+	createCmd.Flags().StringArrayVar(&crossplaneValuesFiles, "values", nil, "Crossplane Helm values file (can be repeated)")
+	createCmd.Flags().StringArrayVar(&crossplaneSetValues, "set", nil, "Crossplane Helm value override, e.g. args={--debug} (can be repeated)")
 	createCmd.MarkFlagRequired("config")
 }
 
@@ -54,8 +92,28 @@ var createCmd = &cobra.Command{
 			return err
 		}
 
+		// Load provider configuration
+		providerConfig, err := config.LoadConfig(clusterConfig)
+		if err != nil {
+			return fmt.Errorf("failed to load provider configuration: %v", err)
+		}
+
+		if useLocalStack {
+			providerConfig.LocalStack.Enabled = true
+		}
+
+		// Validate configuration
+		if err := providerConfig.Validate(); err != nil {
+			return fmt.Errorf("invalid provider configuration: %v", err)
+		}
+
+		clusterImage, err := clusterNodeImage(providerConfig.Cluster)
+		if err != nil {
+			return err
+		}
+
 		// Check if cluster exists
-		kindManager := kind.NewManager()
+		kindManager := kind.NewManagerWithLogger(kind.NewLogger(kindVerbosity))
 		exists, err := kindManager.ClusterExists(clusterName)
 		if err != nil {
 			return fmt.Errorf("failed to check cluster existence: %v", err)
@@ -74,54 +132,71 @@ var createCmd = &cobra.Command{
 
 		// Create Kind cluster
 		fmt.Printf("Creating Kind cluster '%s'...\n", clusterName)
-		if err := kindManager.CreateCluster(kindConfigFile, clusterName); err != nil {
+		if clusterImage != "" {
+			fmt.Printf("Using node image %s\n", clusterImage)
+		}
+		_, err = kindManager.CreateCluster(kindConfigFile, clusterName, kind.CreateOptions{
+			LocalRegistry:                 localRegistry,
+			NodeImage:                     clusterImage,
+			Wait:                          waitForReady,
+			Retain:                        retainNodes,
+			StopBeforeSettingUpKubernetes: stopBeforeSetup,
+			DisplayUsage:                  displayUsage,
+			DisplaySalutation:             displaySalutation,
+			KubeconfigPath:                kubeconfigPath,
+		})
+		if err != nil {
+			if retainNodes {
+				fmt.Printf("The nodes of '%s' were retained for debugging; collect their logs with 'kind export logs --name %s'\n", clusterName, clusterName)
+			}
 			return fmt.Errorf("failed to create Kind cluster: %v", err)
 		}
 		fmt.Printf("Kind cluster '%s' created successfully!\n", clusterName)
 
-		// Initialize provider manager
-		manager, err := provider.NewManager()
-		if err != nil {
-			return fmt.Errorf("failed to create provider manager: %v", err)
+		if stopBeforeSetup {
+			fmt.Println("Stopped before setting up Kubernetes, Crossplane was not installed")
+			return nil
 		}
 
-		// Load provider configuration
-		providerConfig, err := config.LoadConfig(clusterConfig)
+		// Initialize provider manager against the new cluster's context, whatever
+		// the current context is
+		manager, err := provider.NewManager(kube.NewClientFactory(kubeconfigPath, kind.ContextName(clusterName), namespace))
 		if err != nil {
-			return fmt.Errorf("failed to load provider configuration: %v", err)
+			return fmt.Errorf("failed to create provider manager: %v", err)
 		}
+		defer manager.Close()
 
-		// Validate configuration
-		if err := providerConfig.Validate(); err != nil {
-			return fmt.Errorf("invalid provider configuration: %v", err)
+		// pull secrets must exist before Crossplane and the packages are pulled
+		if err := applyRegistrySecrets(ctx, manager, providerConfig.RegistryAuth); err != nil {
+			return err
 		}
 
 		// install crossplane helm chart
 		fmt.Println("\nInstalling Crossplane Helm chart...")
-		if err := InstallCrossplane(ctx, manager); err != nil {
-			return err
-		}
-
-		// Install AWS family provider
-		fmt.Println("\nInstalling AWS provider...")
-		if err := InstallClusterProvider(ctx, manager, providerConfig.AWS.Family); err != nil {
+		if err := InstallCrossplane(ctx, manager, crossplaneConfigWithFlags(providerConfig.Crossplane)); err != nil {
 			return err
 		}
 
-		// Install AWS service providers
-		fmt.Println("\nInstalling AWS service providers...")
-		for _, p := range providerConfig.AWS.Services {
-			if err := InstallClusterProvider(ctx, manager, p); err != nil {
+		packages := providerConfig.Packages()
+		if preloadImages {
+			fmt.Println("\nPreloading runtime images into the cluster nodes (package contents are still fetched from their registry)...")
+			if err := preloadPackageImages(ctx, kindManager, packages); err != nil {
 				return err
 			}
 		}
 
-		// Install other providers
-		fmt.Println("\nInstalling other providers...")
-		for _, p := range providerConfig.OtherProviders {
-			if err := InstallClusterProvider(ctx, manager, p); err != nil {
-				return err
-			}
+		// Install packages concurrently, respecting their dependencies
+		fmt.Printf("\nInstalling packages (%d workers)...\n", installWorkers)
+		results, err := provider.InstallAll(ctx, packages, installWorkers, func(ctx context.Context, p config.Package) error {
+			return InstallClusterPackage(ctx, manager, p.Kind, p.Provider)
+		})
+		printInstallSummary(os.Stdout, results)
+		if err != nil {
+			return fmt.Errorf("failed to install packages: %v", err)
+		}
+
+		if err := applyProviderConfigs(ctx, manager, providerConfig); err != nil {
+			return err
 		}
 
 		fmt.Println("\nCluster setup completed successfully!")
@@ -137,24 +212,97 @@ var createCmd = &cobra.Command{
 	},
 }
 
+// clusterNodeImage resolves the node image from the --image and --k8s-version
+// flags, falling back to the cluster section of the configuration
+func clusterNodeImage(cfg config.ClusterConfig) (string, error) {
+	if nodeImage != "" || k8sVersion != "" {
+		return kind.ResolveNodeImage(nodeImage, k8sVersion)
+	}
+	return kind.ResolveNodeImage(cfg.NodeImage, cfg.KubernetesVersion)
+}
+
+// preloadPackageImages caches the images of the packages, loads them into
+// every node of the cluster, and sets their pull policy to IfNotPresent so
+// the preloaded images are used for the package runtimes. Crossplane's
+// package manager fetches the package contents itself, so the registry must
+// still be reachable.
+func preloadPackageImages(ctx context.Context, kindManager kind.Manager, packages []config.Package) error {
+	archives, err := cachePackageImages(ctx, packages, false)
+	if err != nil {
+		return fmt.Errorf("failed to cache images: %v", err)
+	}
+
+	if err := kindManager.LoadImageArchives(clusterName, archives); err != nil {
+		return fmt.Errorf("failed to load images into cluster: %v", err)
+	}
+
+	for i := range packages {
+		if packages[i].PackagePullPolicy == "" {
+			packages[i].PackagePullPolicy = "IfNotPresent"
+		}
+	}
+
+	return nil
+}
+
+// printInstallSummary writes the outcome of every package install
+func printInstallSummary(w io.Writer, results []provider.InstallResult) {
+	fmt.Fprintln(w, "\nInstall summary:")
+	for _, r := range results {
+		switch {
+		case r.Skipped:
+			fmt.Fprintf(w, "  - %s %s: %v\n", r.Package.Kind, r.Package.Name, r.Err)
+		case r.Err != nil:
+			fmt.Fprintf(w, "  ✗ %s %s: %v\n", r.Package.Kind, r.Package.Name, r.Err)
+		default:
+			fmt.Fprintf(w, "  ✓ %s %s (%s)\n", r.Package.Kind, r.Package.Name, r.Duration.Round(time.Second))
+		}
+	}
+}
+
 func InstallClusterProvider(ctx context.Context, manager provider.Manager, provider config.Provider) error {
-	fmt.Printf("Installing provider %s...\n", provider.Name)
-	if err := manager.Install(ctx, provider, forceProviders); err != nil {
-		return fmt.Errorf("failed to install provider %s: %v", provider.Name, err)
+	return InstallClusterPackage(ctx, manager, config.ProviderKind, provider)
+}
+
+// InstallClusterPackage installs a Crossplane package and waits for it to become healthy
+func InstallClusterPackage(ctx context.Context, manager provider.Manager, kind config.PackageKind, pkg config.Provider) error {
+	kindName := strings.ToLower(string(kind))
+
+	fmt.Printf("Installing %s %s...\n", kindName, pkg.Name)
+	if err := manager.InstallPackage(ctx, kind, pkg, forceProviders); err != nil {
+		return fmt.Errorf("failed to install %s %s: %v", kindName, pkg.Name, err)
 	}
 
-	fmt.Printf("Waiting for provider %s to become healthy...\n", provider.Name)
-	if err := manager.WaitForHealth(ctx, provider.Name); err != nil {
-		return fmt.Errorf("failed to wait for provider %s health: %v", provider.Name, err)
+	fmt.Printf("Waiting for %s %s to become healthy...\n", kindName, pkg.Name)
+	if err := manager.WaitForPackageHealth(ctx, kind, pkg.Name); err != nil {
+		return fmt.Errorf("failed to wait for %s %s health: %v", kindName, pkg.Name, err)
 	}
 
 	return nil
 }
 
-// installCrossplane installs the Crossplane Helm chart
-func InstallCrossplane(ctx context.Context, manager provider.Manager) error {
-	fmt.Println("Installing Crossplane...")
-	if err := manager.InstallCrossplane(ctx); err != nil {
+// crossplaneConfigWithFlags applies the --chart, --crossplane-version,
+// --values and --set flags on top of the configured Crossplane installation
+func crossplaneConfigWithFlags(cfg config.CrossplaneConfig) config.CrossplaneConfig {
+	if crossplaneChart != "" {
+		cfg.Chart = crossplaneChart
+	}
+	if crossplaneVersion != "" {
+		cfg.Version = crossplaneVersion
+	}
+	cfg.ValuesFiles = append(cfg.ValuesFiles, crossplaneValuesFiles...)
+	cfg.Set = append(cfg.Set, crossplaneSetValues...)
+	return cfg
+}
+
+// InstallCrossplane installs the Crossplane Helm chart
+func InstallCrossplane(ctx context.Context, manager provider.Manager, cfg config.CrossplaneConfig) error {
+	if cfg.Version != "" {
+		fmt.Printf("Installing Crossplane %s...\n", cfg.Version)
+	} else {
+		fmt.Println("Installing Crossplane...")
+	}
+	if err := manager.InstallCrossplane(ctx, cfg); err != nil {
 		return fmt.Errorf("failed to install Crossplane: %v", err)
 	}
 
@@ -11,6 +11,7 @@ import (
 
 	"github.com/kanzifucius/crosslab/pkg/config"
 	"github.com/kanzifucius/crosslab/pkg/kind"
+	"github.com/kanzifucius/crosslab/pkg/kube"
 	"github.com/kanzifucius/crosslab/pkg/provider"
 	"gopkg.in/yaml.v3"
 )
@@ -83,7 +84,7 @@ func TestInstallCrossplane(t *testing.T) {
 	_ = kindManager.DeleteCluster(globalClusterName)
 
 	// Create initial cluster
-	err = kindManager.CreateCluster(tmpfile.Name(), globalClusterName)
+	_, err = kindManager.CreateCluster(tmpfile.Name(), globalClusterName, kind.CreateOptions{})
 	if err != nil {
 		t.Fatalf("failed to create test cluster: %v", err)
 	}
@@ -97,12 +98,12 @@ func TestInstallCrossplane(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			//delete all test clusters
 			cleanupTestClusters(t)
-			manager, err := provider.NewManager()
+			manager, err := provider.NewManager(kube.NewClientFactory("", kind.ContextName(globalClusterName), provider.CrossplaneNamespace))
 			if err != nil {
 				t.Fatalf("failed to create manager: %v", err)
 			}
 
-			err = InstallCrossplane(context.Background(), manager)
+			err = InstallCrossplane(context.Background(), manager, config.CrossplaneConfig{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("InstallCrossplane() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -170,17 +171,17 @@ func TestInstallClusterProvider(t *testing.T) {
 	_ = kindManager.DeleteCluster(globalClusterName)
 
 	// Create initial cluster
-	err = kindManager.CreateCluster(tmpfile.Name(), globalClusterName)
+	_, err = kindManager.CreateCluster(tmpfile.Name(), globalClusterName, kind.CreateOptions{})
 	if err != nil {
 		t.Fatalf("failed to create test cluster: %v", err)
 	}
 
 	// Install Crossplane first
-	manager, err := provider.NewManager()
+	manager, err := provider.NewManager(kube.NewClientFactory("", kind.ContextName(globalClusterName), provider.CrossplaneNamespace))
 	if err != nil {
 		t.Fatalf("failed to create manager: %v", err)
 	}
-	err = InstallCrossplane(context.Background(), manager)
+	err = InstallCrossplane(context.Background(), manager, config.CrossplaneConfig{})
 	if err != nil {
 		t.Fatalf("failed to install Crossplane: %v", err)
 	}
@@ -192,7 +193,7 @@ func TestInstallClusterProvider(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			manager, err := provider.NewManager()
+			manager, err := provider.NewManager(kube.NewClientFactory("", kind.ContextName(globalClusterName), provider.CrossplaneNamespace))
 			if err != nil {
 				t.Fatalf("failed to create manager: %v", err)
 			}
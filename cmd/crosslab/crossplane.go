@@ -0,0 +1,117 @@
+package crosslab
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+	"github.com/kanzifucius/crosslab/pkg/provider"
+
+	"github.com/spf13/cobra"
+)
+
+var removePackages bool
+
+func init() {
+	RootCmd.AddCommand(crossplaneCmd)
+	crossplaneCmd.AddCommand(upgradeCrossplaneCmd)
+	crossplaneCmd.AddCommand(uninstallCrossplaneCmd)
+
+	// Add flags to upgrade command
+	upgradeCrossplaneCmd.Flags().StringVarP(&providerConfigFile, "config", "c", ".crosslab/config/crosslab-config.yaml", "Path to the provider configuration file")
+	upgradeCrossplaneCmd.Flags().StringVar(&crossplaneVersion, "crossplane-version", "", "Crossplane Helm chart version (overrides the configuration)")
+	upgradeCrossplaneCmd.Flags().StringVar(&crossplaneChart, "chart", "", "Crossplane chart: a chart name, an oci:// reference, or a local directory or .tgz (overrides the configuration)")
+	upgradeCrossplaneCmd.Flags().StringArrayVar(&crossplaneValuesFiles, "values", nil, "Crossplane Helm values file (can be repeated)")
+	upgradeCrossplaneCmd.Flags().StringArrayVar(&crossplaneSetValues, "set", nil, "Crossplane Helm value override, e.g. args={--debug} (can be repeated)")
+
+	// Add flags to uninstall command
+	uninstallCrossplaneCmd.Flags().BoolVar(&removePackages, "remove-packages", false, "Delete all packages before uninstalling and the Crossplane CRDs afterwards")
+}
+
+var crossplaneCmd = &cobra.Command{
+	Use:   "crossplane",
+	Short: "Manage the Crossplane installation",
+	Long:  `Upgrade and uninstall the Crossplane Helm release`,
+}
+
+var upgradeCrossplaneCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade Crossplane",
+	Long: `Upgrade the Crossplane Helm release using the crossplane section of the
+configuration file, if present, and the --chart, --crossplane-version, --values
+and --set flags`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		crossplaneConfig, registryAuth, err := loadCrossplaneConfig(providerConfigFile)
+		if err != nil {
+			return err
+		}
+
+		manager, err := provider.NewManager(clientFactory())
+		if err != nil {
+			return fmt.Errorf("failed to create provider manager: %v", err)
+		}
+		defer manager.Close()
+
+		if err := applyRegistrySecrets(ctx, manager, registryAuth); err != nil {
+			return err
+		}
+
+		fmt.Println("Upgrading Crossplane...")
+		if err := manager.UpgradeCrossplane(ctx, crossplaneConfig); err != nil {
+			return err
+		}
+
+		fmt.Println("Waiting for Crossplane to become healthy...")
+		if err := manager.WaitForCrossplaneHealth(ctx); err != nil {
+			return fmt.Errorf("failed to wait for Crossplane health: %v", err)
+		}
+
+		fmt.Println("Crossplane upgraded and healthy ✓")
+		return nil
+	},
+}
+
+var uninstallCrossplaneCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Uninstall Crossplane",
+	Long: `Uninstall the Crossplane Helm release.
+With --remove-packages, configurations, functions and providers are deleted
+first, in that order, and the Crossplane CRDs are removed once the release is gone.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		manager, err := provider.NewManager(clientFactory())
+		if err != nil {
+			return fmt.Errorf("failed to create provider manager: %v", err)
+		}
+		defer manager.Close()
+
+		fmt.Println("Uninstalling Crossplane...")
+		if err := manager.UninstallCrossplane(ctx, provider.UninstallOptions{RemovePackages: removePackages}); err != nil {
+			return err
+		}
+
+		fmt.Println("Crossplane uninstalled ✓")
+		return nil
+	},
+}
+
+// loadCrossplaneConfig returns the crossplane section of the configuration
+// file, if it exists, with the Crossplane flags applied, and the registry
+// pull secrets the release references
+func loadCrossplaneConfig(path string) (config.CrossplaneConfig, []config.RegistryAuth, error) {
+	var crossplaneConfig config.CrossplaneConfig
+	var registryAuth []config.RegistryAuth
+	if config.FileExists(path) {
+		providerConfig, err := config.LoadConfig(path)
+		if err != nil {
+			return crossplaneConfig, nil, fmt.Errorf("failed to load provider configuration: %v", err)
+		}
+		crossplaneConfig = providerConfig.Crossplane
+		registryAuth = providerConfig.RegistryAuth
+	}
+
+	return crossplaneConfigWithFlags(crossplaneConfig), registryAuth, nil
+}
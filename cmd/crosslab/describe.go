@@ -0,0 +1,100 @@
+package crosslab
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+	"github.com/kanzifucius/crosslab/pkg/provider"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+var describeKind string
+
+func init() {
+	providerCmd.AddCommand(describeProviderCmd)
+
+	describeProviderCmd.Flags().StringVarP(&describeKind, "kind", "k", "provider", "Package kind: provider, function or configuration")
+}
+
+var describeProviderCmd = &cobra.Command{
+	Use:   "describe <name>",
+	Short: "Show detailed status of an installed package",
+	Long: `Show the Installed and Healthy conditions, current revision, image digest,
+owned CRDs and age of an installed Crossplane package`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		kind, err := config.ParsePackageKind(describeKind)
+		if err != nil {
+			return err
+		}
+
+		manager, err := provider.NewManager(clientFactory())
+		if err != nil {
+			return fmt.Errorf("failed to create provider manager: %v", err)
+		}
+		defer manager.Close()
+
+		status, err := manager.Status(ctx, kind, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to get package status: %v", err)
+		}
+		if status == nil {
+			return fmt.Errorf("%s '%s' is not installed", describeKind, args[0])
+		}
+
+		printPackageStatusDetail(os.Stdout, status)
+		return nil
+	},
+}
+
+// printPackageStatusTable writes one row per package status
+func printPackageStatusTable(w io.Writer, statuses []provider.PackageStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tPACKAGE\tVERSION\tINSTALLED\tHEALTHY\tREVISION\tCRDS\tAGE")
+	for _, s := range statuses {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			s.Name, s.Package, valueOrDash(s.Version), s.Installed.Status, s.Healthy.Status,
+			valueOrDash(s.Revision), s.CRDs, duration.HumanDuration(s.Age()))
+	}
+	return tw.Flush()
+}
+
+// printPackageStatusDetail writes the full status of a single package
+func printPackageStatusDetail(w io.Writer, s *provider.PackageStatus) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Name:\t%s\n", s.Name)
+	fmt.Fprintf(tw, "Kind:\t%s\n", s.Kind)
+	fmt.Fprintf(tw, "Package:\t%s\n", s.Package)
+	fmt.Fprintf(tw, "Version:\t%s\n", valueOrDash(s.Version))
+	fmt.Fprintf(tw, "Revision:\t%s\n", valueOrDash(s.Revision))
+	if s.ImageDigest == "" && s.ImageDigestPrefix != "" {
+		fmt.Fprintf(tw, "Image digest:\t%s... (prefix from revision name)\n", s.ImageDigestPrefix)
+	} else {
+		fmt.Fprintf(tw, "Image digest:\t%s\n", valueOrDash(s.ImageDigest))
+	}
+	fmt.Fprintf(tw, "CRDs owned:\t%d\n", s.CRDs)
+	fmt.Fprintf(tw, "Created:\t%s (%s ago)\n", s.CreatedAt.Format("2006-01-02 15:04:05 MST"), duration.HumanDuration(s.Age()))
+	tw.Flush()
+
+	fmt.Fprintln(w, "Conditions:")
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  TYPE\tSTATUS\tREASON\tMESSAGE")
+	for _, c := range []struct {
+		name      string
+		condition provider.Condition
+	}{
+		{"Installed", s.Installed},
+		{"Healthy", s.Healthy},
+	} {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", c.name, c.condition.Status, valueOrDash(c.condition.Reason), valueOrDash(c.condition.Message))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,173 @@
+package crosslab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+	"github.com/kanzifucius/crosslab/pkg/provider"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	diffConfigFile string
+	diffOutput     string
+)
+
+func init() {
+	providerCmd.AddCommand(diffProvidersCmd)
+
+	diffProvidersCmd.Flags().StringVarP(&diffConfigFile, "config", "c", ".crosslab/config/crosslab-config.yaml", "Path to provider configuration file")
+	diffProvidersCmd.Flags().StringVarP(&diffOutput, "output", "o", "table", "Output format: table, json or yaml")
+}
+
+// diffEntry is a single package difference between the configuration and the cluster
+type diffEntry struct {
+	Status  string             `json:"status" yaml:"status"`
+	Kind    config.PackageKind `json:"kind" yaml:"kind"`
+	Name    string             `json:"name" yaml:"name"`
+	Package string             `json:"package" yaml:"package"`
+	Current string             `json:"current,omitempty" yaml:"current,omitempty"`
+	Desired string             `json:"desired,omitempty" yaml:"desired,omitempty"`
+}
+
+var diffProvidersCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show differences between the configuration and the cluster",
+	Long: `Compare the packages declared in the configuration file with the packages
+installed in the cluster and print the added, removed and changed packages.
+Packages whose options or runtime configuration changed are reported as
+changed.
+Packages Crossplane installed as dependencies of other packages are not
+reported as removed.
+
+The command exits with code 2 when drift is detected, so it can be used to
+gate CI jobs.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		if diffOutput != "table" && diffOutput != "json" && diffOutput != "yaml" {
+			return fmt.Errorf("unsupported output format %q, use table, json or yaml", diffOutput)
+		}
+
+		if err := config.CheckConfigFile(diffConfigFile); err != nil {
+			return err
+		}
+
+		providerConfig, err := config.LoadConfig(diffConfigFile)
+		if err != nil {
+			return fmt.Errorf("failed to load provider configuration: %v", err)
+		}
+
+		if err := providerConfig.Validate(); err != nil {
+			return fmt.Errorf("invalid provider configuration: %v", err)
+		}
+
+		manager, err := provider.NewManager(clientFactory())
+		if err != nil {
+			return fmt.Errorf("failed to create provider manager: %v", err)
+		}
+		defer manager.Close()
+
+		entries, err := computeDiff(ctx, manager, providerConfig)
+		if err != nil {
+			return err
+		}
+
+		if err := printDiff(os.Stdout, entries, diffOutput); err != nil {
+			return err
+		}
+
+		if len(entries) > 0 {
+			return &exitError{code: 2, err: fmt.Errorf("drift detected: %d package(s) differ from the configuration", len(entries))}
+		}
+
+		return nil
+	},
+}
+
+// computeDiff compares the configuration with the cluster. Packages
+// Crossplane installed as dependencies of other packages are not reported as
+// removed.
+func computeDiff(ctx context.Context, manager provider.Manager, cfg *config.Config) ([]diffEntry, error) {
+	plan, err := provider.BuildPlan(ctx, manager, cfg, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare configuration with cluster: %v", err)
+	}
+	return diffEntries(plan), nil
+}
+
+// diffEntries converts plan changes into added, removed and changed entries
+func diffEntries(plan *provider.Plan) []diffEntry {
+	entries := []diffEntry{}
+	for _, c := range plan.Changes {
+		entry := diffEntry{Kind: c.Kind, Name: c.Name}
+		if c.Current != nil {
+			entry.Package = c.Current.Package
+			entry.Current = c.Current.Version
+		}
+		if c.Desired != nil {
+			entry.Package = c.Desired.Package
+			entry.Desired = c.Desired.Version
+		}
+
+		switch c.Action {
+		case provider.ActionInstall:
+			entry.Status = "added"
+		case provider.ActionUpgrade:
+			entry.Status = "changed"
+			if c.Current.Package != c.Desired.Package {
+				entry.Current = c.Current.Ref()
+				entry.Desired = c.Desired.Ref()
+			}
+		case provider.ActionPrune:
+			entry.Status = "removed"
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// printDiff writes the diff entries in the requested output format
+func printDiff(w io.Writer, entries []diffEntry, output string) error {
+	switch output {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	case "yaml":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		return encoder.Encode(entries)
+	}
+
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "No differences between the configuration and the cluster ✓")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tKIND\tNAME\tPACKAGE\tCLUSTER\tCONFIG")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", strings.ToUpper(e.Status), e.Kind, e.Name, e.Package, valueOrDash(e.Current), valueOrDash(e.Desired))
+	}
+	return tw.Flush()
+}
+
+// valueOrDash returns s, or "-" when s is empty
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,92 @@
+package crosslab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+	"github.com/kanzifucius/crosslab/pkg/provider"
+)
+
+func TestDiffEntries(t *testing.T) {
+	plan := provider.ComputePlan(
+		[]config.Package{
+			{Kind: config.ProviderKind, Provider: config.Provider{Name: "provider-aws-s3", Package: "xpkg.upbound.io/upbound/provider-aws-s3", Version: "v1.1.0"}},
+			{Kind: config.FunctionKind, Provider: config.Provider{Name: "function-go-templating", Package: "xpkg.upbound.io/crossplane-contrib/function-go-templating", Version: "v0.9.2"}},
+		},
+		[]config.Package{
+			{Kind: config.ProviderKind, Provider: config.Provider{Name: "provider-aws-s3", Package: "xpkg.upbound.io/upbound/provider-aws-s3", Version: "v1.0.0"}},
+			{Kind: config.ProviderKind, Provider: config.Provider{Name: "provider-helm", Package: "xpkg.upbound.io/upbound/provider-helm", Version: "v0.20.4"}},
+		},
+		true,
+	)
+
+	entries := diffEntries(plan)
+	want := []diffEntry{
+		{Status: "changed", Kind: config.ProviderKind, Name: "provider-aws-s3", Package: "xpkg.upbound.io/upbound/provider-aws-s3", Current: "v1.0.0", Desired: "v1.1.0"},
+		{Status: "added", Kind: config.FunctionKind, Name: "function-go-templating", Package: "xpkg.upbound.io/crossplane-contrib/function-go-templating", Desired: "v0.9.2"},
+		{Status: "removed", Kind: config.ProviderKind, Name: "provider-helm", Package: "xpkg.upbound.io/upbound/provider-helm", Current: "v0.20.4"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("diffEntries() returned %d entries, want %d", len(entries), len(want))
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("diffEntries()[%d] = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := printDiff(&buf, entries, "json"); err != nil {
+		t.Fatalf("printDiff() error = %v", err)
+	}
+	var decoded []diffEntry
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("printDiff() produced invalid JSON: %v", err)
+	}
+	if len(decoded) != len(want) {
+		t.Errorf("printDiff() JSON has %d entries, want %d", len(decoded), len(want))
+	}
+}
+
+// lockManager serves installed packages and the dependencies from Crossplane's Lock
+type lockManager struct {
+	provider.Manager
+	installed    map[config.PackageKind][]config.Provider
+	dependencies []string
+}
+
+func (m *lockManager) ListPackages(ctx context.Context, kind config.PackageKind) ([]config.Provider, error) {
+	return m.installed[kind], nil
+}
+
+func (m *lockManager) Drifted(ctx context.Context, kind config.PackageKind, p config.Provider) (bool, error) {
+	return false, nil
+}
+
+func (m *lockManager) Dependencies(ctx context.Context) ([]string, error) {
+	return m.dependencies, nil
+}
+
+func TestComputeDiffIgnoresDependencies(t *testing.T) {
+	platformRef := config.Provider{Name: "platform-ref-aws", Package: "xpkg.upbound.io/upbound/platform-ref-aws", Version: "v1.4.0"}
+	manager := &lockManager{
+		installed: map[config.PackageKind][]config.Provider{
+			config.ConfigurationKind: {platformRef},
+			config.ProviderKind: {
+				{Name: "upbound-provider-family-aws", Package: "xpkg.upbound.io/upbound/provider-family-aws", Version: "v1.1.0"},
+			},
+		},
+		dependencies: []string{"xpkg.upbound.io/upbound/provider-family-aws"},
+	}
+
+	entries, err := computeDiff(context.Background(), manager, &config.Config{Configurations: []config.Provider{platformRef}})
+	if err != nil {
+		t.Fatalf("computeDiff() error = %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("computeDiff() = %+v, want no drift", entries)
+	}
+}
@@ -0,0 +1,75 @@
+package crosslab
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kanzifucius/crosslab/pkg/doctor"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	doctorKindConfig     string
+	doctorProviderConfig string
+)
+
+func init() {
+	RootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().StringVarP(&doctorKindConfig, "config", "c", ".crosslab/kind-config.yaml", "Path to the Kind cluster configuration file")
+	doctorCmd.Flags().StringVarP(&doctorProviderConfig, "provider-config", "p", ".crosslab/config/crosslab-config.yaml", "Path to the provider configuration file")
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the local environment",
+	Long: `Check that Docker is running, the host ports of the Kind configuration are
+free, the inotify limits suit multi-node clusters, a kubeconfig is available,
+and the .crosslab configuration files are valid. Every problem is printed with
+a hint on how to fix it.
+
+The command exits with code 2 when a check fails.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reports := doctor.Run(context.Background(), doctorChecks())
+		printDoctorReports(os.Stdout, reports)
+
+		if doctor.Failed(reports) {
+			return &exitError{code: 2, err: fmt.Errorf("some checks failed")}
+		}
+		return nil
+	},
+}
+
+// doctorChecks returns the checks run by the doctor command, in order
+func doctorChecks() []doctor.Check {
+	return []doctor.Check{
+		doctor.NewDockerCheck(),
+		doctor.NewPortCheck(doctorKindConfig),
+		doctor.NewInotifyCheck(),
+		doctor.NewKubeconfigCheck(clientFactory()),
+		doctor.NewKindConfigCheck(doctorKindConfig),
+		doctor.NewProviderConfigCheck(doctorProviderConfig),
+	}
+}
+
+// printDoctorReports writes one line per check, followed by its hint
+func printDoctorReports(w io.Writer, reports []doctor.Report) {
+	for _, r := range reports {
+		symbol := "✓"
+		switch r.Status {
+		case doctor.StatusWarn:
+			symbol = "!"
+		case doctor.StatusFail:
+			symbol = "✗"
+		}
+
+		fmt.Fprintf(w, "%s %s: %s\n", symbol, r.Name, r.Message)
+		if r.Status != doctor.StatusOK && r.Hint != "" {
+			fmt.Fprintf(w, "    → %s\n", r.Hint)
+		}
+	}
+}
@@ -2,16 +2,21 @@ package crosslab
 
 import (
 	"github.com/kanzifucius/crosslab/pkg/config"
+	"github.com/kanzifucius/crosslab/pkg/kind"
 	"github.com/spf13/cobra"
 )
 
 var (
-	outputDir string
+	outputDir      string
+	initNodeImage  string
+	initK8sVersion string
 )
 
 func init() {
 	RootCmd.AddCommand(initCmd)
 	initCmd.Flags().StringVarP(&outputDir, "output-dir", "o", ".crosslab", "Output directory for configuration files (default: .crosslab in current directory)")
+	initCmd.Flags().StringVar(&initNodeImage, "image", "", "Node image set on every node of the generated Kind configuration")
+	initCmd.Flags().StringVar(&initK8sVersion, "k8s-version", "", "Kubernetes version of the known node image set on every node of the generated Kind configuration, e.g. 1.31")
 }
 
 var initCmd = &cobra.Command{
@@ -23,7 +28,13 @@ This command will create:
 - kind-config.yaml: Kind cluster configuration
 - config/providers.yaml: Crossplane provider configuration`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		nodeImage, err := kind.ResolveNodeImage(initNodeImage, initK8sVersion)
+		if err != nil {
+			return err
+		}
+
 		initializer := config.NewInitializer(outputDir)
+		initializer.NodeImage = nodeImage
 		return initializer.Initialize()
 	},
 }
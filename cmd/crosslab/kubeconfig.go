@@ -0,0 +1,66 @@
+package crosslab
+
+import (
+	"fmt"
+
+	"github.com/kanzifucius/crosslab/pkg/kind"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	kubeconfigCluster  string
+	kubeconfigOutput   string
+	kubeconfigPrint    bool
+	kubeconfigInternal bool
+)
+
+func init() {
+	clusterCmd.AddCommand(kubeconfigCmd)
+
+	// Add flags to kubeconfig command
+	kubeconfigCmd.Flags().StringVarP(&kubeconfigCluster, "name", "n", "kind", "Name of the Kind cluster")
+	kubeconfigCmd.Flags().StringVarP(&kubeconfigOutput, "output", "o", "", "Kubeconfig file to merge the cluster into (defaults to --kubeconfig, $KUBECONFIG or ~/.kube/config)")
+	kubeconfigCmd.Flags().BoolVar(&kubeconfigPrint, "print", false, "Print the kubeconfig instead of merging it")
+	kubeconfigCmd.Flags().BoolVar(&kubeconfigInternal, "internal", false, "Use the API server address within the kind network, for clients running in containers")
+}
+
+var kubeconfigCmd = &cobra.Command{
+	Use:   "kubeconfig",
+	Short: "Export the kubeconfig of a Kind cluster",
+	Long: `Merge the kubeconfig of a Kind cluster into a kubeconfig file as context
+kind-<name> and make it the current context, or print it with --print`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kindManager := kind.NewManager()
+		exists, err := kindManager.ClusterExists(kubeconfigCluster)
+		if err != nil {
+			return fmt.Errorf("failed to check cluster existence: %v", err)
+		}
+		if !exists {
+			return fmt.Errorf("cluster '%s' does not exist", kubeconfigCluster)
+		}
+
+		if kubeconfigPrint {
+			kubeconfig, err := kindManager.KubeConfig(kubeconfigCluster, kubeconfigInternal)
+			if err != nil {
+				return err
+			}
+			fmt.Print(kubeconfig)
+			return nil
+		}
+
+		output := kubeconfigOutput
+		if output == "" {
+			output = kubeconfigPath
+		}
+		if err := kindManager.ExportKubeConfig(kubeconfigCluster, output, kubeconfigInternal); err != nil {
+			return err
+		}
+
+		if output == "" {
+			output = "the default kubeconfig"
+		}
+		fmt.Printf("Context %s written to %s ✓\n", kind.ContextName(kubeconfigCluster), output)
+		return nil
+	},
+}
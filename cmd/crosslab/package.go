@@ -0,0 +1,180 @@
+package crosslab
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+	"github.com/kanzifucius/crosslab/pkg/kind"
+	"github.com/kanzifucius/crosslab/pkg/provider"
+	"github.com/kanzifucius/crosslab/pkg/xpkg"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/spf13/cobra"
+)
+
+var (
+	packageOutput       string
+	packageRuntimeImage string
+	packageIgnore       []string
+	packageTag          string
+	packageName         string
+	installPushed       bool
+)
+
+func init() {
+	RootCmd.AddCommand(packageCmd)
+	packageCmd.AddCommand(buildPackageCmd)
+	packageCmd.AddCommand(pushPackageCmd)
+
+	// Add flags to build command
+	buildPackageCmd.Flags().StringVarP(&packageOutput, "output", "o", "", "Path of the package archive (defaults to <name>.xpkg)")
+	buildPackageCmd.Flags().StringVar(&packageRuntimeImage, "runtime-image", "", "Runtime image to embed, for provider and function packages")
+	buildPackageCmd.Flags().StringSliceVar(&packageIgnore, "ignore", []string{"examples"}, "Directories of the package that are not included")
+
+	// Add flags to push command
+	pushPackageCmd.Flags().StringVarP(&packageTag, "tag", "t", "", "Package reference to push to (e.g. localhost:5001/platform-ref:v0.1.0)")
+	pushPackageCmd.Flags().StringVar(&packageRuntimeImage, "runtime-image", "", "Runtime image to embed when building from a directory")
+	pushPackageCmd.Flags().StringSliceVar(&packageIgnore, "ignore", []string{"examples"}, "Directories of the package that are not included when building from a directory")
+	pushPackageCmd.Flags().BoolVar(&installPushed, "install", false, "Install the pushed package into the cluster and wait for it to become healthy")
+	pushPackageCmd.Flags().StringVarP(&packageName, "name", "n", "", "Name of the installed package (defaults to the package metadata name)")
+	pushPackageCmd.MarkFlagRequired("tag")
+}
+
+var packageCmd = &cobra.Command{
+	Use:   "package",
+	Short: "Build and push Crossplane packages",
+	Long:  `Build Crossplane packages from local directories, push them to a registry and install them`,
+}
+
+var buildPackageCmd = &cobra.Command{
+	Use:   "build <dir>",
+	Short: "Build a Crossplane package",
+	Long: `Build an .xpkg package from a directory holding crossplane.yaml and the
+package manifests, such as XRDs and Compositions`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		img, meta, err := xpkg.Build(args[0], xpkg.BuildOptions{RuntimeImage: packageRuntimeImage, Ignore: packageIgnore})
+		if err != nil {
+			return fmt.Errorf("failed to build package: %v", err)
+		}
+
+		output := packageOutput
+		if output == "" {
+			output = meta.Name + xpkg.Extension
+		}
+
+		if err := xpkg.Write(img, output); err != nil {
+			return err
+		}
+
+		fmt.Printf("Built %s %s to %s ✓\n", strings.ToLower(string(meta.Kind)), meta.Name, output)
+		return nil
+	},
+}
+
+var pushPackageCmd = &cobra.Command{
+	Use:   "push <dir|file.xpkg>",
+	Short: "Push a Crossplane package to a registry",
+	Long: `Push a package to a registry, building it first when given a package directory.
+With --install the package is installed by digest, so every push rolls out
+the new content even when the tag is reused. Packages on localhost can only
+be installed from the local registry of crosslab cluster create --local-registry.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		if installPushed {
+			if err := checkInstallable(packageTag); err != nil {
+				return err
+			}
+		}
+
+		img, meta, err := loadPackage(args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Pushing %s %s to %s...\n", strings.ToLower(string(meta.Kind)), meta.Name, packageTag)
+		digest, err := xpkg.Push(img, packageTag)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Pushed %s@%s ✓\n", packageTag, digest)
+
+		if !installPushed {
+			return nil
+		}
+
+		pkg, _ := config.ParsePackageRef(packageTag)
+		p := config.Provider{
+			Name:    meta.Name,
+			Package: pkg,
+			Version: digest,
+		}
+		if packageName != "" {
+			p.Name = packageName
+		}
+
+		manager, err := provider.NewManager(clientFactory())
+		if err != nil {
+			return fmt.Errorf("failed to create provider manager: %v", err)
+		}
+		defer manager.Close()
+
+		return installPackageAndWait(ctx, manager, meta.Kind, p)
+	},
+}
+
+// loadPackage builds the package in a directory or reads a package archive
+func loadPackage(path string) (v1.Image, *xpkg.Meta, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read package: %v", err)
+	}
+
+	if info.IsDir() {
+		img, meta, err := xpkg.Build(path, xpkg.BuildOptions{RuntimeImage: packageRuntimeImage, Ignore: packageIgnore})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to build package: %v", err)
+		}
+		return img, meta, nil
+	}
+
+	img, err := xpkg.Load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	meta, err := xpkg.ImageMeta(img)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read package metadata: %v", err)
+	}
+
+	return img, meta, nil
+}
+
+// checkInstallable rejects package references on a loopback registry other
+// than the local registry, which is the only one the cluster can reach
+func checkInstallable(tag string) error {
+	ref, err := name.ParseReference(tag)
+	if err != nil {
+		return fmt.Errorf("invalid tag %s: %v", tag, err)
+	}
+
+	registry := ref.Context().RegistryStr()
+	host := registry
+	if h, _, err := net.SplitHostPort(registry); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if registry != kind.RegistryHost && (host == "localhost" || ip != nil && ip.IsLoopback()) {
+		return fmt.Errorf("cannot install from %s, which the cluster cannot reach: push to the local registry at %s instead", registry, kind.RegistryHost)
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package crosslab
+
+import "testing"
+
+func TestCheckInstallable(t *testing.T) {
+	tests := []struct {
+		tag     string
+		wantErr bool
+	}{
+		{tag: "localhost:5001/platform-ref:v0.1.0"},
+		{tag: "registry.example.com/platform-ref:v0.1.0"},
+		{tag: "localhost:5000/platform-ref:v0.1.0", wantErr: true},
+		{tag: "127.0.0.1:5001/platform-ref:v0.1.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			err := checkInstallable(tt.tag)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkInstallable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
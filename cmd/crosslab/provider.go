@@ -3,6 +3,8 @@ package crosslab
 import (
 	"context"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/kanzifucius/crosslab/pkg/config"
 	"github.com/kanzifucius/crosslab/pkg/provider"
@@ -15,6 +17,7 @@ var (
 	providerVersion    string
 	forceReinstall     bool
 	providerConfigFile string
+	useLocalStack      bool
 )
 
 func init() {
@@ -22,19 +25,29 @@ func init() {
 	providerCmd.AddCommand(installProviderCmd)
 	providerCmd.AddCommand(listProvidersCmd)
 	providerCmd.AddCommand(installAllCmd)
+	providerCmd.AddCommand(upgradeProviderCmd)
 
 	// Add flags to install command
 	installProviderCmd.Flags().StringVarP(&providerPackage, "package", "p", "", "Provider package (e.g., xpkg.upbound.io/upbound/provider-aws)")
 	installProviderCmd.Flags().StringVarP(&providerVersion, "version", "v", "", "Provider version")
 	installProviderCmd.Flags().StringVarP(&clusterName, "name", "n", "", "Provider name")
-	installProviderCmd.Flags().BoolVarP(&forceReinstall, "force", "f", false, "Force reinstall if provider exists")
+	installProviderCmd.Flags().BoolVarP(&forceReinstall, "force", "f", false, "Upgrade the provider in place if it already exists")
 	installProviderCmd.MarkFlagRequired("package")
 	installProviderCmd.MarkFlagRequired("version")
 	installProviderCmd.MarkFlagRequired("name")
 
+	// Add flags to upgrade command
+	upgradeProviderCmd.Flags().StringVarP(&providerPackage, "package", "p", "", "Provider package (defaults to the installed package)")
+	upgradeProviderCmd.Flags().StringVarP(&providerVersion, "version", "v", "", "Provider version to upgrade to")
+	upgradeProviderCmd.Flags().StringVarP(&clusterName, "name", "n", "", "Provider name")
+	upgradeProviderCmd.MarkFlagRequired("version")
+	upgradeProviderCmd.MarkFlagRequired("name")
+
 	// Add flags to install-all command
-	installAllCmd.Flags().BoolVarP(&forceReinstall, "force", "f", false, "Force reinstall if providers exist")
+	installAllCmd.Flags().BoolVarP(&forceReinstall, "force", "f", false, "Re-apply packages in place even if the installed version matches")
+	installAllCmd.Flags().IntVarP(&installWorkers, "workers", "w", provider.DefaultInstallWorkers, "Maximum number of packages installed concurrently")
 	installAllCmd.Flags().StringVarP(&providerConfigFile, "config", "c", ".crosslab/config/crosslab-config.yaml", "Path to provider configuration file")
+	installAllCmd.Flags().BoolVar(&useLocalStack, "localstack", false, "Deploy LocalStack and point the AWS providers at it (overrides the configuration)")
 }
 
 var providerCmd = &cobra.Command{
@@ -50,10 +63,11 @@ var installProviderCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
 
-		manager, err := provider.NewManager()
+		manager, err := provider.NewManager(clientFactory())
 		if err != nil {
 			return fmt.Errorf("failed to create provider manager: %v", err)
 		}
+		defer manager.Close()
 
 		p := config.Provider{
 			Name:    clusterName,
@@ -77,6 +91,48 @@ var installProviderCmd = &cobra.Command{
 	},
 }
 
+var upgradeProviderCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade an installed Crossplane provider in place",
+	Long: `Upgrade an installed Crossplane provider by patching its package reference.
+The provider is not deleted, so its CRDs and managed resources are kept while
+the new provider revision becomes active.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		manager, err := provider.NewManager(clientFactory())
+		if err != nil {
+			return fmt.Errorf("failed to create provider manager: %v", err)
+		}
+		defer manager.Close()
+
+		installed, err := manager.GetPackage(ctx, config.ProviderKind, clusterName)
+		if err != nil {
+			return fmt.Errorf("failed to get provider: %v", err)
+		}
+		if installed == nil {
+			return fmt.Errorf("provider '%s' is not installed", clusterName)
+		}
+
+		p := config.Provider{
+			Name:    clusterName,
+			Package: providerPackage,
+			Version: providerVersion,
+		}
+		if p.Package == "" {
+			p.Package = installed.Package
+		}
+
+		fmt.Printf("Upgrading provider '%s' from %s to %s...\n", p.Name, installed.Ref(), p.Ref())
+		if err := manager.Upgrade(ctx, config.ProviderKind, p); err != nil {
+			return fmt.Errorf("failed to upgrade provider: %v", err)
+		}
+
+		fmt.Printf("Provider '%s' upgraded and healthy!\n", p.Name)
+		return nil
+	},
+}
+
 var listProvidersCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List installed Crossplane providers",
@@ -84,12 +140,13 @@ var listProvidersCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
 
-		manager, err := provider.NewManager()
+		manager, err := provider.NewManager(clientFactory())
 		if err != nil {
 			return fmt.Errorf("failed to create provider manager: %v", err)
 		}
+		defer manager.Close()
 
-		providers, err := manager.List(ctx)
+		providers, err := manager.ListStatus(ctx, config.ProviderKind)
 		if err != nil {
 			return fmt.Errorf("failed to list providers: %v", err)
 		}
@@ -99,19 +156,14 @@ var listProvidersCmd = &cobra.Command{
 			return nil
 		}
 
-		fmt.Println("Installed Crossplane providers:")
-		for _, p := range providers {
-			fmt.Printf("- %s (%s)\n", p.Name, p.Package)
-		}
-
-		return nil
+		return printPackageStatusTable(os.Stdout, providers)
 	},
 }
 
 var installAllCmd = &cobra.Command{
 	Use:   "install-all",
-	Short: "Install all required Crossplane providers",
-	Long:  `Install all required Crossplane providers for the project`,
+	Short: "Install all required Crossplane packages",
+	Long:  `Install all Crossplane providers, functions and configurations declared in the project configuration`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
 
@@ -126,55 +178,145 @@ var installAllCmd = &cobra.Command{
 			return fmt.Errorf("failed to load provider configuration: %v", err)
 		}
 
+		if useLocalStack {
+			providerConfig.LocalStack.Enabled = true
+		}
+
 		// Validate configuration
 		if err := providerConfig.Validate(); err != nil {
 			return fmt.Errorf("invalid provider configuration: %v", err)
 		}
 
-		manager, err := provider.NewManager()
+		manager, err := provider.NewManager(clientFactory())
 		if err != nil {
 			return fmt.Errorf("failed to create provider manager: %v", err)
 		}
+		defer manager.Close()
 
-		// Install AWS family provider
-		fmt.Println("Installing AWS provider...")
-		if err := installAndWait(ctx, manager, providerConfig.AWS.Family); err != nil {
+		if err := applyRegistrySecrets(ctx, manager, providerConfig.RegistryAuth); err != nil {
 			return err
 		}
 
-		// Install AWS service providers
-		fmt.Println("\nInstalling AWS service providers...")
-		for _, p := range providerConfig.AWS.Services {
-			if err := installAndWait(ctx, manager, p); err != nil {
-				return err
-			}
+		// Install packages concurrently, respecting their dependencies
+		fmt.Printf("Installing packages (%d workers)...\n", installWorkers)
+		results, err := provider.InstallAll(ctx, providerConfig.Packages(), installWorkers, func(ctx context.Context, p config.Package) error {
+			return installPackageAndWait(ctx, manager, p.Kind, p.Provider)
+		})
+		printInstallSummary(os.Stdout, results)
+		if err != nil {
+			return fmt.Errorf("failed to install packages: %v", err)
 		}
 
-		// Install other providers
-		fmt.Println("\nInstalling other providers...")
-		for _, p := range providerConfig.OtherProviders {
-			if err := installAndWait(ctx, manager, p); err != nil {
-				return err
-			}
+		if err := applyProviderConfigs(ctx, manager, providerConfig); err != nil {
+			return err
 		}
 
-		fmt.Println("\nAll providers installed successfully!")
+		fmt.Println("\nAll packages installed successfully!")
 		return nil
 	},
 }
 
-func installAndWait(ctx context.Context, manager provider.Manager, p config.Provider) error {
-	fmt.Printf("Installing %s...\n", p.Name)
+// applyRegistrySecrets creates the pull secrets of the private registries
+func applyRegistrySecrets(ctx context.Context, manager provider.Manager, registryAuth []config.RegistryAuth) error {
+	for _, auth := range registryAuth {
+		if err := manager.ApplyRegistrySecret(ctx, auth); err != nil {
+			return fmt.Errorf("failed to create pull secret for %s: %v", auth.Registry, err)
+		}
+		fmt.Printf("Pull secret %s for %s ✓\n", auth.SecretName(), auth.Registry)
+	}
+
+	return nil
+}
+
+// applyProviderConfigs deploys LocalStack when it is enabled, then creates the
+// ProviderConfigs and their credentials Secrets
+func applyProviderConfigs(ctx context.Context, manager provider.Manager, cfg *config.Config) error {
+	providerConfigs := cfg.ProviderConfigs
+	if cfg.LocalStack.Enabled {
+		fmt.Printf("\nDeploying %s...\n", cfg.LocalStack.ImageOrDefault())
+		endpoint, err := manager.DeployLocalStack(ctx, cfg.LocalStack)
+		if err != nil {
+			return fmt.Errorf("failed to deploy LocalStack: %v", err)
+		}
+		fmt.Printf("LocalStack is available at %s ✓\n", endpoint)
+		providerConfigs = cfg.ProviderConfigsWithLocalStack(endpoint)
+	}
+
+	if len(providerConfigs) == 0 {
+		return nil
+	}
 
-	if err := manager.Install(ctx, p, forceReinstall); err != nil {
-		return fmt.Errorf("failed to install provider %s: %v", p.Name, err)
+	fmt.Println("\nApplying provider configs...")
+	for _, pc := range providerConfigs {
+		if err := manager.ApplyProviderConfig(ctx, pc); err != nil {
+			return fmt.Errorf("failed to apply provider config %s: %v", pc.Name, err)
+		}
+		fmt.Printf("%s %s (%s credentials) ✓\n", pc.ProviderConfigKind(), pc.Name, pc.Credentials.Source)
+	}
+
+	return nil
+}
+
+// installPackageAndWait installs a package, or upgrades it in place when the
+// installed version or settings differ from the configured ones, and waits
+// for it to become healthy. A new package with the Manual revision activation
+// policy is not waited for, as its first revision stays inactive.
+func installPackageAndWait(ctx context.Context, manager provider.Manager, kind config.PackageKind, p config.Provider) error {
+	kindName := strings.ToLower(string(kind))
+
+	installed, err := manager.GetPackage(ctx, kind, p.Name)
+	if err != nil {
+		return fmt.Errorf("failed to get %s %s: %v", kindName, p.Name, err)
+	}
+
+	if installed != nil {
+		if installed.Ref() == p.Ref() {
+			drifted, err := manager.Drifted(ctx, kind, p)
+			if err != nil {
+				return fmt.Errorf("failed to compare %s %s with the configuration: %v", kindName, p.Name, err)
+			}
+			if !drifted && !forceReinstall {
+				fmt.Printf("%s %s is up to date (%s) ✓\n", kind, p.Name, p.Version)
+				return nil
+			}
+			fmt.Printf("Updating the settings of %s (%s)...\n", p.Name, p.Ref())
+		} else {
+			fmt.Printf("Upgrading %s from %s to %s...\n", p.Name, installed.Ref(), p.Ref())
+		}
+		if err := manager.Upgrade(ctx, kind, p); err != nil {
+			return fmt.Errorf("failed to upgrade %s %s: %v", kindName, p.Name, err)
+		}
+		if p.RevisionActivationPolicy == config.ManualActivation {
+			if err := printStagedRevision(ctx, manager, kind, p); err != nil {
+				return err
+			}
+		}
+	} else {
+		fmt.Printf("Installing %s...\n", p.Name)
+		if err := manager.InstallPackage(ctx, kind, p, false); err != nil {
+			return fmt.Errorf("failed to install %s %s: %v", kindName, p.Name, err)
+		}
+		if p.RevisionActivationPolicy == config.ManualActivation {
+			return printStagedRevision(ctx, manager, kind, p)
+		}
 	}
 
 	fmt.Printf("Waiting for %s to become healthy...\n", p.Name)
-	if err := manager.WaitForHealth(ctx, p.Name); err != nil {
-		return fmt.Errorf("failed while waiting for provider %s: %v", p.Name, err)
+	if err := manager.WaitForPackageHealth(ctx, kind, p.Name); err != nil {
+		return fmt.Errorf("failed while waiting for %s %s: %v", kindName, p.Name, err)
 	}
 
-	fmt.Printf("Provider %s is healthy ✓\n", p.Name)
+	fmt.Printf("%s %s is healthy ✓\n", kind, p.Name)
+	return nil
+}
+
+// printStagedRevision prints the revision of a package with the Manual
+// revision activation policy that waits to be activated
+func printStagedRevision(ctx context.Context, manager provider.Manager, kind config.PackageKind, p config.Provider) error {
+	revision, err := manager.Revision(ctx, kind, p)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s %s revision %s is staged; set its desiredState to Active to activate it\n", kind, p.Name, revision)
 	return nil
 }
@@ -1,26 +1,72 @@
 package crosslab
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 
+	"github.com/kanzifucius/crosslab/pkg/kube"
+	"github.com/kanzifucius/crosslab/pkg/provider"
+
 	"github.com/spf13/cobra"
 )
 
+var (
+	kubeconfigPath string
+	kubeContext    string
+	namespace      string
+)
+
+func init() {
+	// Add global flags selecting the cluster
+	RootCmd.PersistentFlags().StringVar(&kubeconfigPath, "kubeconfig", "", "Path to the kubeconfig file (defaults to $KUBECONFIG or ~/.kube/config)")
+	RootCmd.PersistentFlags().StringVar(&kubeContext, "context", "", "Kubeconfig context to use (defaults to the current context)")
+	RootCmd.PersistentFlags().StringVar(&namespace, "namespace", provider.CrossplaneNamespace, "Namespace Crossplane is installed into")
+}
+
 var RootCmd = &cobra.Command{
 	Use:   "crosslab",
 	Short: "Crosslab is a CLI tool",
 	Long: `A CLI application built with love using Cobra.
 This is the root command for the application.`,
+	// Errors are printed once to stderr by Execute, keeping stdout for
+	// machine-readable output
+	SilenceErrors: true,
 	Run: func(cmd *cobra.Command, args []string) {
 		cmd.Help()
 	},
 }
 
+// clientFactory returns the client factory for the cluster selected by the global flags
+func clientFactory() *kube.ClientFactory {
+	return kube.NewClientFactory(kubeconfigPath, kubeContext, namespace)
+}
+
+// exitError is an error that makes the CLI exit with a specific code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() {
 	if err := RootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(reportError(os.Stderr, err))
+	}
+}
+
+// reportError writes a command error to w and returns the exit code for it
+func reportError(w io.Writer, err error) int {
+	fmt.Fprintln(w, "Error:", err)
+
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
 	}
+	return 1
 }
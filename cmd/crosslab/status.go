@@ -0,0 +1,273 @@
+package crosslab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+	"github.com/kanzifucius/crosslab/pkg/kind"
+	"github.com/kanzifucius/crosslab/pkg/kube"
+	"github.com/kanzifucius/crosslab/pkg/provider"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	statusClusterName string
+	statusConfigFile  string
+	statusOutput      string
+)
+
+func init() {
+	clusterCmd.AddCommand(clusterStatusCmd)
+
+	clusterStatusCmd.Flags().StringVarP(&statusClusterName, "name", "n", "kind", "Name of the Kind cluster")
+	clusterStatusCmd.Flags().StringVarP(&statusConfigFile, "config", "c", ".crosslab/config/crosslab-config.yaml", "Path to the provider configuration file")
+	clusterStatusCmd.Flags().StringVarP(&statusOutput, "output", "o", "table", "Output format: table or json")
+}
+
+// Package health states reported by cluster status
+const (
+	packageHealthy   = "healthy"
+	packageUnhealthy = "unhealthy"
+	packageMissing   = "missing"
+	packageOutdated  = "outdated"
+)
+
+// clusterStatus is the combined health of a lab cluster
+type clusterStatus struct {
+	Name       string                     `json:"name"`
+	Healthy    bool                       `json:"healthy"`
+	Nodes      []kind.NodeStatus          `json:"nodes"`
+	API        apiStatus                  `json:"api"`
+	Crossplane *provider.CrossplaneStatus `json:"crossplane,omitempty"`
+	Packages   []packageHealth            `json:"packages"`
+}
+
+// apiStatus is the reachability of the Kubernetes API
+type apiStatus struct {
+	Reachable bool   `json:"reachable"`
+	Version   string `json:"version,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// packageHealth compares a configured package with the installed one
+type packageHealth struct {
+	Kind      config.PackageKind `json:"kind"`
+	Name      string             `json:"name"`
+	Package   string             `json:"package"`
+	Version   string             `json:"version"`
+	Installed string             `json:"installed,omitempty"`
+	Status    string             `json:"status"`
+	Message   string             `json:"message,omitempty"`
+}
+
+var clusterStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether a lab cluster is usable",
+	Long: `Report the state of the kind node containers, the reachability of the
+Kubernetes API, the Crossplane Helm release and deployment, and the health of
+every package in the configuration file compared with the cluster.
+
+The command exits with code 2 when anything is unhealthy.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		if statusOutput != "table" && statusOutput != "json" {
+			return fmt.Errorf("unsupported output format %q, use table or json", statusOutput)
+		}
+
+		if err := config.CheckConfigFile(statusConfigFile); err != nil {
+			return err
+		}
+
+		providerConfig, err := config.LoadConfig(statusConfigFile)
+		if err != nil {
+			return fmt.Errorf("failed to load provider configuration: %v", err)
+		}
+
+		kindManager := kind.NewManager()
+		exists, err := kindManager.ClusterExists(statusClusterName)
+		if err != nil {
+			return fmt.Errorf("failed to check cluster existence: %v", err)
+		}
+		if !exists {
+			return fmt.Errorf("cluster '%s' does not exist", statusClusterName)
+		}
+
+		return runClusterStatus(ctx, cmd.OutOrStdout(), kindManager, statusClusterName, providerConfig.Packages(), statusOutput)
+	},
+}
+
+// runClusterStatus writes the status of a cluster to w and returns an
+// exitError when it is unhealthy. Only the status itself goes to w, so the
+// json output stays parseable.
+func runClusterStatus(ctx context.Context, w io.Writer, kindManager kind.Manager, name string, packages []config.Package, output string) error {
+	status, err := collectClusterStatus(ctx, kindManager, name, packages)
+	if err != nil {
+		return err
+	}
+
+	if err := printClusterStatus(w, status, output); err != nil {
+		return err
+	}
+
+	if !status.Healthy {
+		return &exitError{code: 2, err: fmt.Errorf("cluster '%s' is unhealthy", name)}
+	}
+
+	return nil
+}
+
+// collectClusterStatus gathers the health of the nodes, the API, Crossplane
+// and the configured packages. Checks that depend on the API are skipped
+// when it cannot be reached.
+func collectClusterStatus(ctx context.Context, kindManager kind.Manager, name string, packages []config.Package) (*clusterStatus, error) {
+	status := &clusterStatus{Name: name, Packages: []packageHealth{}}
+
+	nodes, err := kindManager.NodeStatuses(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node status: %v", err)
+	}
+	status.Nodes = nodes
+
+	// Use the cluster's own context unless one is selected explicitly
+	clusterContext := kubeContext
+	if clusterContext == "" {
+		clusterContext = kind.ContextName(name)
+	}
+	manager, err := provider.NewManager(kube.NewClientFactory(kubeconfigPath, clusterContext, namespace))
+	if err == nil {
+		defer manager.Close()
+		status.API.Version, err = manager.ServerVersion(ctx)
+	}
+	if err != nil {
+		status.API.Error = err.Error()
+		status.Healthy = status.healthy()
+		return status, nil
+	}
+	status.API.Reachable = true
+
+	status.Crossplane, err = manager.CrossplaneStatus(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Crossplane status: %v", err)
+	}
+
+	for _, p := range packages {
+		installed, err := manager.Status(ctx, p.Kind, p.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get package status: %v", err)
+		}
+		status.Packages = append(status.Packages, comparePackage(p, installed))
+	}
+
+	status.Healthy = status.healthy()
+	return status, nil
+}
+
+// comparePackage compares a configured package with its installed status,
+// which is nil when the package is not installed
+func comparePackage(p config.Package, installed *provider.PackageStatus) packageHealth {
+	health := packageHealth{Kind: p.Kind, Name: p.Name, Package: p.Package, Version: p.Version}
+
+	switch {
+	case installed == nil:
+		health.Status = packageMissing
+	case installed.Package != p.Package || installed.Version != p.Version:
+		health.Installed = installed.Version
+		health.Status = packageOutdated
+		if installed.Package != p.Package {
+			health.Installed = installed.Package + ":" + installed.Version
+		}
+	case !installed.Healthy.True():
+		health.Installed = installed.Version
+		health.Status = packageUnhealthy
+		health.Message = installed.Healthy.Message
+		if health.Message == "" {
+			health.Message = installed.Healthy.Reason
+		}
+	default:
+		health.Installed = installed.Version
+		health.Status = packageHealthy
+	}
+
+	return health
+}
+
+// healthy reports whether every node runs, the API is reachable, Crossplane
+// is healthy and every configured package is installed and healthy
+func (s *clusterStatus) healthy() bool {
+	if len(s.Nodes) == 0 || !s.API.Reachable || s.Crossplane == nil || !s.Crossplane.Healthy() {
+		return false
+	}
+	for _, n := range s.Nodes {
+		if !n.Running() {
+			return false
+		}
+	}
+	for _, p := range s.Packages {
+		if p.Status != packageHealthy {
+			return false
+		}
+	}
+	return true
+}
+
+// printClusterStatus writes the cluster status in the requested output format
+func printClusterStatus(w io.Writer, s *clusterStatus, output string) error {
+	if output == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(s)
+	}
+
+	fmt.Fprintf(w, "Cluster: %s\n", s.Name)
+
+	fmt.Fprintln(w, "\nNodes:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  NAME\tROLE\tSTATE")
+	for _, n := range s.Nodes {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", n.Name, n.Role, n.State)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w, "\nKubernetes API:")
+	if s.API.Reachable {
+		fmt.Fprintf(w, "  reachable, %s\n", s.API.Version)
+	} else {
+		fmt.Fprintf(w, "  unreachable: %s\n", s.API.Error)
+	}
+
+	if s.Crossplane != nil {
+		fmt.Fprintln(w, "\nCrossplane:")
+		c := s.Crossplane
+		if c.Installed {
+			fmt.Fprintf(w, "  release %s (chart %s, revision %d, %s)\n", valueOrDash(c.AppVersion), valueOrDash(c.ChartVersion), c.Revision, c.ReleaseStatus)
+			fmt.Fprintf(w, "  deployment available: %s (%d/%d ready)\n", c.Available.Status, c.ReadyReplicas, c.Replicas)
+		} else {
+			fmt.Fprintln(w, "  not installed")
+		}
+	}
+
+	if s.API.Reachable {
+		fmt.Fprintln(w, "\nPackages:")
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "  KIND\tNAME\tCONFIG\tCLUSTER\tSTATUS\tMESSAGE")
+		for _, p := range s.Packages {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\n", p.Kind, p.Name, valueOrDash(p.Version), valueOrDash(p.Installed), strings.ToUpper(p.Status), valueOrDash(p.Message))
+		}
+		tw.Flush()
+	}
+
+	if s.Healthy {
+		fmt.Fprintln(w, "\nCluster is healthy ✓")
+	} else {
+		fmt.Fprintln(w, "\nCluster is unhealthy ✗")
+	}
+	return nil
+}
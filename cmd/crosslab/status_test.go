@@ -0,0 +1,120 @@
+package crosslab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+	"github.com/kanzifucius/crosslab/pkg/kind"
+	"github.com/kanzifucius/crosslab/pkg/provider"
+)
+
+func TestComparePackage(t *testing.T) {
+	p := config.Package{Kind: config.ProviderKind, Provider: config.Provider{Name: "provider-aws-s3", Package: "xpkg.upbound.io/upbound/provider-aws-s3", Version: "v1.1.0"}}
+	healthy := provider.Condition{Status: "True"}
+
+	tests := []struct {
+		name      string
+		installed *provider.PackageStatus
+		want      string
+		message   string
+	}{
+		{name: "missing", want: packageMissing},
+		{
+			name:      "outdated",
+			installed: &provider.PackageStatus{Package: p.Package, Version: "v1.0.0", Healthy: healthy},
+			want:      packageOutdated,
+		},
+		{
+			name:      "unhealthy",
+			installed: &provider.PackageStatus{Package: p.Package, Version: p.Version, Healthy: provider.Condition{Status: "False", Message: "cannot pull image"}},
+			want:      packageUnhealthy,
+			message:   "cannot pull image",
+		},
+		{
+			name:      "healthy",
+			installed: &provider.PackageStatus{Package: p.Package, Version: p.Version, Healthy: healthy},
+			want:      packageHealthy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := comparePackage(p, tt.installed)
+			if got.Status != tt.want {
+				t.Errorf("comparePackage() status = %s, want %s", got.Status, tt.want)
+			}
+			if got.Message != tt.message {
+				t.Errorf("comparePackage() message = %q, want %q", got.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestClusterStatusHealthy(t *testing.T) {
+	status := &clusterStatus{
+		Name:  "lab",
+		Nodes: []kind.NodeStatus{{Name: "lab-control-plane", Role: "control-plane", State: "running"}},
+		API:   apiStatus{Reachable: true, Version: "v1.32.2"},
+		Crossplane: &provider.CrossplaneStatus{
+			Installed:     true,
+			ReleaseStatus: "deployed",
+			Available:     provider.Condition{Status: "True"},
+		},
+		Packages: []packageHealth{{Kind: config.ProviderKind, Name: "provider-helm", Status: packageHealthy}},
+	}
+	if !status.healthy() {
+		t.Errorf("healthy() = false, want true")
+	}
+
+	status.Packages = append(status.Packages, packageHealth{Kind: config.FunctionKind, Name: "function-auto-ready", Status: packageMissing})
+	if status.healthy() {
+		t.Errorf("healthy() = true with a missing package, want false")
+	}
+
+	status.Packages = status.Packages[:1]
+	status.Nodes = append(status.Nodes, kind.NodeStatus{Name: "lab-worker", Role: "worker", State: "exited"})
+	if status.healthy() {
+		t.Errorf("healthy() = true with a stopped node, want false")
+	}
+
+	var buf bytes.Buffer
+	if err := printClusterStatus(&buf, status, "json"); err != nil {
+		t.Fatalf("printClusterStatus() error = %v", err)
+	}
+	var decoded clusterStatus
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("printClusterStatus() produced invalid JSON: %v", err)
+	}
+	if len(decoded.Nodes) != 2 || decoded.Crossplane == nil {
+		t.Errorf("printClusterStatus() JSON = %s", buf.String())
+	}
+}
+
+func TestRunClusterStatusUnhealthyJSON(t *testing.T) {
+	// Point at a missing kubeconfig so the API is unreachable
+	oldKubeconfig := kubeconfigPath
+	kubeconfigPath = filepath.Join(t.TempDir(), "kubeconfig")
+	defer func() { kubeconfigPath = oldKubeconfig }()
+
+	var stdout, stderr bytes.Buffer
+	err := runClusterStatus(context.Background(), &stdout, kind.NewMockManager(), "lab", nil, "json")
+	if code := reportError(&stderr, err); code != 2 {
+		t.Errorf("reportError() = %d, want 2", code)
+	}
+
+	var decoded clusterStatus
+	if err := json.Unmarshal(stdout.Bytes(), &decoded); err != nil {
+		t.Fatalf("stdout is not a single JSON document: %v\n%s", err, stdout.String())
+	}
+	if decoded.Healthy || decoded.API.Reachable {
+		t.Errorf("stdout = %s, want an unhealthy cluster with an unreachable API", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "cluster 'lab' is unhealthy") {
+		t.Errorf("stderr = %q, want the unhealthy error", stderr.String())
+	}
+}
@@ -0,0 +1,104 @@
+package crosslab
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+	"github.com/kanzifucius/crosslab/pkg/provider"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncConfigFile string
+	syncPrune      bool
+	syncDryRun     bool
+)
+
+func init() {
+	RootCmd.AddCommand(syncCmd)
+
+	syncCmd.Flags().StringVarP(&syncConfigFile, "config", "c", ".crosslab/config/crosslab-config.yaml", "Path to provider configuration file")
+	syncCmd.Flags().BoolVar(&syncPrune, "prune", false, "Delete packages that are installed but not in the configuration")
+	syncCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "Print the plan without applying it")
+}
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Reconcile the cluster with the configuration file",
+	Long: `Compare the packages declared in the configuration file with the packages
+installed in the cluster, print the resulting plan and apply it.
+
+Missing packages are installed, and packages at a different version or
+with changed options or runtime configuration are upgraded in place. With
+--prune, packages that are not in the configuration are deleted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		if err := config.CheckConfigFile(syncConfigFile); err != nil {
+			return err
+		}
+
+		providerConfig, err := config.LoadConfig(syncConfigFile)
+		if err != nil {
+			return fmt.Errorf("failed to load provider configuration: %v", err)
+		}
+
+		if err := providerConfig.Validate(); err != nil {
+			return fmt.Errorf("invalid provider configuration: %v", err)
+		}
+
+		manager, err := provider.NewManager(clientFactory())
+		if err != nil {
+			return fmt.Errorf("failed to create provider manager: %v", err)
+		}
+		defer manager.Close()
+
+		plan, err := provider.BuildPlan(ctx, manager, providerConfig, syncPrune)
+		if err != nil {
+			return fmt.Errorf("failed to compute plan: %v", err)
+		}
+
+		if plan.Empty() {
+			fmt.Println("Cluster is in sync with the configuration ✓")
+			return nil
+		}
+
+		fmt.Println("Plan:")
+		printPlan(os.Stdout, plan)
+
+		if syncDryRun {
+			return nil
+		}
+
+		fmt.Println()
+		if err := plan.Apply(ctx, manager, os.Stdout); err != nil {
+			return err
+		}
+
+		fmt.Println("\nCluster is in sync with the configuration ✓")
+		return nil
+	},
+}
+
+// printPlan writes the plan changes as a table
+func printPlan(w io.Writer, plan *provider.Plan) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tKIND\tNAME\tCURRENT\tDESIRED")
+	for _, c := range plan.Changes {
+		current, desired := "-", "-"
+		if c.Current != nil {
+			current = c.Current.Ref()
+		}
+		if c.Desired != nil {
+			desired = c.Desired.Ref()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", strings.ToUpper(string(c.Action)), c.Kind, c.Name, current, desired)
+	}
+	tw.Flush()
+}
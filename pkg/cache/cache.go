@@ -0,0 +1,82 @@
+// Package cache manages the crosslab cache directory, which holds artifacts
+// that installs can use without network access
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// DirEnv overrides the location of the cache directory
+const DirEnv = "CROSSLAB_CACHE_DIR"
+
+// Dir returns the crosslab cache directory, $CROSSLAB_CACHE_DIR or the
+// crosslab directory in the user cache directory
+func Dir() (string, error) {
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return dir, nil
+	}
+
+	userCache, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find user cache directory: %v", err)
+	}
+
+	return filepath.Join(userCache, "crosslab"), nil
+}
+
+// ChartsDir returns the directory Helm charts are cached in, creating it if needed
+func ChartsDir() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	charts := filepath.Join(dir, "charts")
+	if err := os.MkdirAll(charts, 0755); err != nil {
+		return "", fmt.Errorf("failed to create chart cache directory: %v", err)
+	}
+
+	return charts, nil
+}
+
+// FindChart returns the path of a cached chart archive, as saved by Helm as
+// <name>-<version>.tgz. An empty version finds the highest cached version.
+func FindChart(name, version string) (string, bool) {
+	dir, err := ChartsDir()
+	if err != nil {
+		return "", false
+	}
+
+	if version != "" {
+		path := filepath.Join(dir, fmt.Sprintf("%s-%s.tgz", name, strings.TrimPrefix(version, "v")))
+		if _, err := os.Stat(path); err != nil {
+			return "", false
+		}
+		return path, true
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, name+"-*.tgz"))
+	if err != nil {
+		return "", false
+	}
+
+	var latestPath string
+	var latest *semver.Version
+	for _, path := range matches {
+		v, err := semver.NewVersion(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), name+"-"), ".tgz"))
+		if err != nil {
+			// Another chart whose name starts with the same prefix
+			continue
+		}
+		if latest == nil || v.GreaterThan(latest) {
+			latest, latestPath = v, path
+		}
+	}
+
+	return latestPath, latest != nil
+}
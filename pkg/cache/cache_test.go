@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindChart(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(DirEnv, dir)
+
+	charts, err := ChartsDir()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "charts"), charts)
+
+	for _, name := range []string{"crossplane-1.18.2.tgz", "crossplane-1.19.0.tgz", "crossplane-1.9.0.tgz", "crossplane-types-2.0.0.tgz"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(charts, name), nil, 0644))
+	}
+
+	t.Run("pinned version", func(t *testing.T) {
+		path, ok := FindChart("crossplane", "1.18.2")
+		assert.True(t, ok)
+		assert.Equal(t, filepath.Join(charts, "crossplane-1.18.2.tgz"), path)
+
+		path, ok = FindChart("crossplane", "v1.18.2")
+		assert.True(t, ok)
+		assert.Equal(t, filepath.Join(charts, "crossplane-1.18.2.tgz"), path)
+	})
+
+	t.Run("latest version", func(t *testing.T) {
+		path, ok := FindChart("crossplane", "")
+		assert.True(t, ok)
+		assert.Equal(t, filepath.Join(charts, "crossplane-1.19.0.tgz"), path)
+	})
+
+	t.Run("not cached", func(t *testing.T) {
+		_, ok := FindChart("crossplane", "1.20.0")
+		assert.False(t, ok)
+
+		_, ok = FindChart("upbound-crossplane", "")
+		assert.False(t, ok)
+	})
+}
+
+func TestCachedImage(t *testing.T) {
+	t.Setenv(DirEnv, t.TempDir())
+
+	assert.Equal(t, "xpkg.upbound.io_upbound_provider-aws-s3_v1.tar", ImageArchiveName("xpkg.upbound.io/upbound/provider-aws-s3:v1"))
+	assert.Equal(t, "localhost_5001_provider_sha256_abc.tar", ImageArchiveName("localhost:5001/provider@sha256:abc"))
+
+	_, ok := CachedImage("xpkg.upbound.io/upbound/provider-aws-s3:v1")
+	assert.False(t, ok)
+
+	images, err := ImagesDir()
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(images, "xpkg.upbound.io_upbound_provider-aws-s3_v1.tar"), nil, 0644))
+
+	path, ok := CachedImage("xpkg.upbound.io/upbound/provider-aws-s3:v1")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(images, "xpkg.upbound.io_upbound_provider-aws-s3_v1.tar"), path)
+}
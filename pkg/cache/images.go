@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ImagesDir returns the directory image archives are cached in, creating it if needed
+func ImagesDir() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	images := filepath.Join(dir, "images")
+	if err := os.MkdirAll(images, 0755); err != nil {
+		return "", fmt.Errorf("failed to create image cache directory: %v", err)
+	}
+
+	return images, nil
+}
+
+// ImageArchiveName returns the file name an image reference is cached under
+func ImageArchiveName(image string) string {
+	return strings.NewReplacer("/", "_", ":", "_", "@", "_").Replace(image) + ".tar"
+}
+
+// CachedImage returns the path of the cached archive of an image
+func CachedImage(image string) (string, bool) {
+	dir, err := ImagesDir()
+	if err != nil {
+		return "", false
+	}
+
+	path := filepath.Join(dir, ImageArchiveName(image))
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// PullImage saves an image into the cache with docker pull and docker save
+// and returns the path of its archive. Cached images are only pulled again
+// when refresh is set.
+func PullImage(ctx context.Context, image string, refresh bool) (string, error) {
+	if path, ok := CachedImage(image); ok && !refresh {
+		return path, nil
+	}
+
+	dir, err := ImagesDir()
+	if err != nil {
+		return "", err
+	}
+
+	if out, err := exec.CommandContext(ctx, "docker", "pull", image).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to pull image %s: %v: %s", image, err, strings.TrimSpace(string(out)))
+	}
+
+	// Save to a temporary file so an interrupted save never leaves a partial archive in the cache
+	path := filepath.Join(dir, ImageArchiveName(image))
+	tmp := path + ".tmp"
+	if out, err := exec.CommandContext(ctx, "docker", "save", "-o", tmp, image).CombinedOutput(); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to save image %s: %v: %s", image, err, strings.TrimSpace(string(out)))
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("failed to cache image %s: %v", image, err)
+	}
+
+	return path, nil
+}
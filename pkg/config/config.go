@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,18 +15,192 @@ type Provider struct {
 	Name    string `yaml:"name"`
 	Package string `yaml:"package"`
 	Version string `yaml:"version"`
+
+	// DependsOn lists the names of packages that must be healthy before
+	// this package is installed
+	DependsOn []string `yaml:"dependsOn,omitempty"`
+
+	// PackagePullPolicy is the pull policy of the package image, e.g. IfNotPresent
+	PackagePullPolicy string `yaml:"packagePullPolicy,omitempty"`
+	// PackagePullSecrets are Secrets in the Crossplane namespace used to pull the package
+	PackagePullSecrets []string `yaml:"packagePullSecrets,omitempty"`
+	// RevisionActivationPolicy is Automatic, or Manual to stage upgrades
+	RevisionActivationPolicy string `yaml:"revisionActivationPolicy,omitempty"`
+	// RevisionHistoryLimit is the number of inactive revisions kept
+	RevisionHistoryLimit *int64 `yaml:"revisionHistoryLimit,omitempty"`
+	// SkipDependencyResolution skips installing the dependencies of the package
+	SkipDependencyResolution bool `yaml:"skipDependencyResolution,omitempty"`
+
+	// RuntimeConfig configures the Deployment of a provider or function,
+	// on top of the top-level runtimeConfig
+	RuntimeConfig *RuntimeConfig `yaml:"runtimeConfig,omitempty"`
 }
 
-// AWSConfig represents AWS-specific provider configuration
-type AWSConfig struct {
+// Ref returns the full package reference, e.g. xpkg.upbound.io/upbound/provider-aws-s3:v1
+func (p Provider) Ref() string {
+	if p.Version == "" {
+		return p.Package
+	}
+	if strings.HasPrefix(p.Version, "sha256:") {
+		return p.Package + "@" + p.Version
+	}
+	return p.Package + ":" + p.Version
+}
+
+// Revision activation policies of a package
+const (
+	AutomaticActivation = "Automatic"
+	ManualActivation    = "Manual"
+)
+
+// ValidateInstallOptions checks the pull and revision options of the package
+func (p Provider) ValidateInstallOptions() error {
+	switch p.PackagePullPolicy {
+	case "", "Always", "Never", "IfNotPresent":
+	default:
+		return fmt.Errorf("packagePullPolicy must be Always, Never or IfNotPresent, got %q", p.PackagePullPolicy)
+	}
+
+	switch p.RevisionActivationPolicy {
+	case "", AutomaticActivation, ManualActivation:
+	default:
+		return fmt.Errorf("revisionActivationPolicy must be Automatic or Manual, got %q", p.RevisionActivationPolicy)
+	}
+
+	if p.RevisionHistoryLimit != nil && *p.RevisionHistoryLimit < 0 {
+		return fmt.Errorf("revisionHistoryLimit must not be negative")
+	}
+
+	for _, secret := range p.PackagePullSecrets {
+		if secret == "" {
+			return fmt.Errorf("packagePullSecrets must not contain empty names")
+		}
+	}
+
+	return nil
+}
+
+// ParsePackageRef splits a package reference into its package and version.
+// Digest references return the digest (e.g. sha256:...) as the version.
+func ParsePackageRef(ref string) (pkg string, version string) {
+	if i := strings.LastIndex(ref, "@"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+
+	// A colon before the last slash belongs to a registry port, not a tag
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:]
+	}
+
+	return ref, ""
+}
+
+// PackageKind is the kind of a Crossplane package
+type PackageKind string
+
+// PackageKinds lists the Crossplane package kinds in install order
+var PackageKinds = []PackageKind{ProviderKind, FunctionKind, ConfigurationKind}
+
+const (
+	// ProviderKind is a pkg.crossplane.io Provider package
+	ProviderKind PackageKind = "Provider"
+	// FunctionKind is a pkg.crossplane.io Function package
+	FunctionKind PackageKind = "Function"
+	// ConfigurationKind is a pkg.crossplane.io Configuration package
+	ConfigurationKind PackageKind = "Configuration"
+)
+
+// ParsePackageKind parses a package kind name such as "provider" or "Function"
+func ParsePackageKind(name string) (PackageKind, error) {
+	for _, kind := range PackageKinds {
+		if strings.EqualFold(name, string(kind)) {
+			return kind, nil
+		}
+	}
+	return "", fmt.Errorf("unknown package kind %q, use provider, function or configuration", name)
+}
+
+// Package is a configured Crossplane package together with its kind
+type Package struct {
+	Kind PackageKind
+	Provider
+}
+
+// ProviderFamily represents a provider family such as the Upbound AWS, GCP or
+// Azure families: a family provider and the service providers built on it
+type ProviderFamily struct {
 	Family   Provider   `yaml:"family"`
 	Services []Provider `yaml:"services"`
 }
 
+// AWSConfig represents AWS-specific provider configuration
+type AWSConfig = ProviderFamily
+
+// CrossplaneConfig configures the Crossplane Helm release
+type CrossplaneConfig struct {
+	// Repo is the Helm repository URL of the chart
+	Repo string `yaml:"repo,omitempty"`
+	// Chart is the chart name in the repository, an oci:// reference, or a
+	// local chart directory or .tgz archive relative to the configuration file
+	Chart string `yaml:"chart,omitempty"`
+	// Version pins the chart version; the latest version is used when empty
+	Version string `yaml:"version,omitempty"`
+	// ValuesFiles are Helm values files, relative to the configuration file
+	ValuesFiles []string `yaml:"valuesFiles,omitempty"`
+	// Values are inline Helm values, applied on top of ValuesFiles
+	Values map[string]interface{} `yaml:"values,omitempty"`
+	// Set are Helm --set style overrides, applied last
+	Set []string `yaml:"set,omitempty"`
+	// ImagePullSecrets are added to the imagePullSecrets value of the release;
+	// the registryAuth secrets are added when the configuration is loaded
+	ImagePullSecrets []string `yaml:"imagePullSecrets,omitempty"`
+}
+
+// LocalChart reports whether Chart refers to a chart on disk rather than in a repository
+func (c CrossplaneConfig) LocalChart() bool {
+	for _, prefix := range []string{"/", "./", "../"} {
+		if strings.HasPrefix(c.Chart, prefix) {
+			return true
+		}
+	}
+	return strings.HasSuffix(c.Chart, ".tgz") || strings.HasSuffix(c.Chart, ".tar.gz")
+}
+
+// ClusterConfig configures the nodes of the kind cluster
+type ClusterConfig struct {
+	// KubernetesVersion selects a known kindest/node image, e.g. 1.31 or 1.31.6
+	KubernetesVersion string `yaml:"kubernetesVersion,omitempty"`
+	// NodeImage is the image of every node, e.g. kindest/node:v1.31.6
+	NodeImage string `yaml:"nodeImage,omitempty"`
+}
+
 // Config represents the complete provider configuration
 type Config struct {
-	AWS            AWSConfig  `yaml:"aws"`
-	OtherProviders []Provider `yaml:"otherProviders"`
+	Cluster    ClusterConfig    `yaml:"cluster,omitempty"`
+	Crossplane CrossplaneConfig `yaml:"crossplane,omitempty"`
+
+	Families       map[string]ProviderFamily `yaml:"families,omitempty"`
+	OtherProviders []Provider                `yaml:"otherProviders"`
+	Functions      []Provider                `yaml:"functions,omitempty"`
+	Configurations []Provider                `yaml:"configurations,omitempty"`
+
+	// ProviderConfigs are created, with their credentials Secrets, once the
+	// providers are installed
+	ProviderConfigs []ProviderConfig `yaml:"providerConfigs,omitempty"`
+
+	// LocalStack deploys an AWS stand-in and points the AWS providers at it
+	LocalStack LocalStackConfig `yaml:"localstack,omitempty"`
+
+	// RuntimeConfig is the default runtime configuration of every provider
+	// and function
+	RuntimeConfig *RuntimeConfig `yaml:"runtimeConfig,omitempty"`
+
+	// RegistryAuth are pull secrets of private registries
+	RegistryAuth []RegistryAuth `yaml:"registryAuth,omitempty"`
+
+	// AWS is the legacy top-level AWS family. LoadConfig moves it into
+	// Families["aws"] so existing configuration files keep working.
+	AWS *AWSConfig `yaml:"aws,omitempty"`
 }
 
 // LoadConfig loads provider configuration from a YAML file
@@ -62,9 +238,62 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("error parsing config file: %v", err)
 	}
 
+	if err := config.migrateLegacyAWS(); err != nil {
+		return nil, err
+	}
+
+	// Values files and local charts are relative to the configuration file
+	for i, f := range config.Crossplane.ValuesFiles {
+		if !filepath.IsAbs(f) {
+			config.Crossplane.ValuesFiles[i] = filepath.Join(filepath.Dir(configPath), f)
+		}
+	}
+	if config.Crossplane.LocalChart() && !filepath.IsAbs(config.Crossplane.Chart) {
+		config.Crossplane.Chart = filepath.Join(filepath.Dir(configPath), config.Crossplane.Chart)
+	}
+
+	// So are the files mounted into package runtimes
+	for _, r := range config.runtimeConfigs() {
+		r.resolveFiles(filepath.Dir(configPath))
+	}
+
+	// The Crossplane release pulls its images with the registry secrets too
+	for _, auth := range config.RegistryAuth {
+		config.Crossplane.ImagePullSecrets = appendUnique(config.Crossplane.ImagePullSecrets, auth.SecretName())
+	}
+
 	return config, nil
 }
 
+// migrateLegacyAWS moves the legacy top-level aws block into Families
+func (c *Config) migrateLegacyAWS() error {
+	if c.AWS == nil {
+		return nil
+	}
+
+	if _, ok := c.Families["aws"]; ok {
+		return fmt.Errorf("aws family is defined both under 'aws' and 'families.aws'")
+	}
+
+	if c.Families == nil {
+		c.Families = map[string]ProviderFamily{}
+	}
+	c.Families["aws"] = *c.AWS
+	c.AWS = nil
+
+	return nil
+}
+
+// FamilyNames returns the configured family names in a stable order
+func (c *Config) FamilyNames() []string {
+	names := make([]string, 0, len(c.Families))
+	for name := range c.Families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // GetDefaultConfigPath returns the default configuration file path
 func GetDefaultConfigPath() string {
 	// Try common locations
@@ -87,15 +316,156 @@ func GetDefaultConfigPath() string {
 	return ""
 }
 
+// runtimeConfigs returns the runtime configurations of the configuration,
+// the default first
+func (c *Config) runtimeConfigs() []*RuntimeConfig {
+	runtimeConfigs := []*RuntimeConfig{c.RuntimeConfig}
+	for _, name := range c.FamilyNames() {
+		family := c.Families[name]
+		runtimeConfigs = append(runtimeConfigs, family.Family.RuntimeConfig)
+		for _, service := range family.Services {
+			runtimeConfigs = append(runtimeConfigs, service.RuntimeConfig)
+		}
+	}
+	for _, list := range [][]Provider{c.OtherProviders, c.Functions, c.Configurations} {
+		for _, p := range list {
+			runtimeConfigs = append(runtimeConfigs, p.RuntimeConfig)
+		}
+	}
+	return runtimeConfigs
+}
+
+// Packages returns every configured package in install order: provider
+// families, other providers, functions and finally configurations.
+//
+// Implicit dependencies are added to the returned packages: service
+// providers depend on their family provider, and configurations without an
+// explicit dependsOn depend on every provider and function.
+func (c *Config) Packages() []Package {
+	var packages []Package
+	for _, name := range c.FamilyNames() {
+		family := c.Families[name]
+		packages = append(packages, Package{Kind: ProviderKind, Provider: c.withDefaults(family.Family)})
+		for _, service := range family.Services {
+			service.DependsOn = appendUnique(append([]string{}, service.DependsOn...), family.Family.Name)
+			packages = append(packages, Package{Kind: ProviderKind, Provider: c.withDefaults(service)})
+		}
+	}
+
+	for _, p := range c.OtherProviders {
+		packages = append(packages, Package{Kind: ProviderKind, Provider: c.withDefaults(p)})
+	}
+
+	for _, f := range c.Functions {
+		packages = append(packages, Package{Kind: FunctionKind, Provider: c.withDefaults(f)})
+	}
+
+	var runtimeDeps []string
+	for _, p := range packages {
+		runtimeDeps = append(runtimeDeps, p.Name)
+	}
+
+	for _, cfg := range c.Configurations {
+		if len(cfg.DependsOn) == 0 {
+			cfg.DependsOn = runtimeDeps
+		}
+		packages = append(packages, Package{Kind: ConfigurationKind, Provider: c.withPullSecrets(cfg)})
+	}
+
+	return packages
+}
+
+// withDefaults merges the default runtime configuration into a provider or
+// function and adds the pull secrets of its registry
+func (c *Config) withDefaults(p Provider) Provider {
+	p.RuntimeConfig = mergeRuntimeConfig(c.RuntimeConfig, p.RuntimeConfig)
+	return c.withPullSecrets(p)
+}
+
+// appendUnique appends value to values unless it is already present
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// CheckDependencies verifies that package names are unique, that every
+// dependency refers to one of the given packages and that the dependencies
+// contain no cycles
+func CheckDependencies(packages []Package) error {
+	deps := map[string][]string{}
+	for _, p := range packages {
+		if _, ok := deps[p.Name]; ok {
+			return fmt.Errorf("duplicate package name %s", p.Name)
+		}
+		deps[p.Name] = p.DependsOn
+	}
+
+	for _, p := range packages {
+		for _, dep := range p.DependsOn {
+			if _, ok := deps[dep]; !ok {
+				return fmt.Errorf("package %s depends on unknown package %s", p.Name, dep)
+			}
+		}
+	}
+
+	// Depth-first search; a package met again while still on the stack is a cycle
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+		for _, dep := range deps[name] {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+
+	for _, p := range packages {
+		if err := visit(p.Name, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Validate validates the provider configuration
 func (c *Config) Validate() error {
-	if c.AWS.Family.Name == "" || c.AWS.Family.Package == "" || c.AWS.Family.Version == "" {
-		return fmt.Errorf("aws family provider configuration is incomplete")
+	if err := c.migrateLegacyAWS(); err != nil {
+		return err
 	}
 
-	for i, service := range c.AWS.Services {
-		if service.Name == "" || service.Package == "" || service.Version == "" {
-			return fmt.Errorf("aws service provider at index %d is incomplete", i)
+	if c.Cluster.KubernetesVersion != "" && c.Cluster.NodeImage != "" {
+		return fmt.Errorf("cluster sets both kubernetesVersion and nodeImage")
+	}
+
+	for _, name := range c.FamilyNames() {
+		family := c.Families[name]
+		if family.Family.Name == "" || family.Family.Package == "" || family.Family.Version == "" {
+			return fmt.Errorf("%s family provider configuration is incomplete", name)
+		}
+
+		for i, service := range family.Services {
+			if service.Name == "" || service.Package == "" || service.Version == "" {
+				return fmt.Errorf("%s service provider at index %d is incomplete", name, i)
+			}
 		}
 	}
 
@@ -105,5 +475,52 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	for i, function := range c.Functions {
+		if function.Name == "" || function.Package == "" || function.Version == "" {
+			return fmt.Errorf("function at index %d is incomplete", i)
+		}
+	}
+
+	for i, configuration := range c.Configurations {
+		if configuration.Name == "" || configuration.Package == "" || configuration.Version == "" {
+			return fmt.Errorf("configuration at index %d is incomplete", i)
+		}
+	}
+
+	if err := CheckDependencies(c.Packages()); err != nil {
+		return err
+	}
+
+	for _, p := range c.Packages() {
+		if err := p.ValidateInstallOptions(); err != nil {
+			return fmt.Errorf("%s %s: %v", strings.ToLower(string(p.Kind)), p.Name, err)
+		}
+		if p.RuntimeConfig == nil {
+			continue
+		}
+		if p.Kind == ConfigurationKind {
+			return fmt.Errorf("configuration %s cannot have a runtimeConfig", p.Name)
+		}
+		if err := p.RuntimeConfig.Validate(); err != nil {
+			return fmt.Errorf("runtimeConfig of %s: %v", p.Name, err)
+		}
+	}
+
+	for i, pc := range c.ProviderConfigs {
+		if err := pc.Validate(); err != nil {
+			return fmt.Errorf("provider config at index %d: %v", i, err)
+		}
+	}
+
+	if err := c.validateLocalStack(); err != nil {
+		return err
+	}
+
+	for i, auth := range c.RegistryAuth {
+		if err := auth.Validate(); err != nil {
+			return fmt.Errorf("registry auth at index %d: %v", i, err)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "crosslab-config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigFamilies(t *testing.T) {
+	t.Run("families", func(t *testing.T) {
+		path := writeConfig(t, `
+families:
+  gcp:
+    family: {name: upbound-provider-gcp, package: xpkg.upbound.io/upbound/provider-family-gcp, version: v1}
+    services:
+      - {name: provider-gcp-storage, package: xpkg.upbound.io/upbound/provider-gcp-storage, version: v1}
+  aws:
+    family: {name: upbound-provider-aws, package: xpkg.upbound.io/upbound/provider-family-aws, version: v1}
+`)
+		cfg, err := LoadConfig(path)
+		assert.NoError(t, err)
+		assert.NoError(t, cfg.Validate())
+		assert.Equal(t, []string{"aws", "gcp"}, cfg.FamilyNames())
+		assert.Equal(t, "provider-gcp-storage", cfg.Families["gcp"].Services[0].Name)
+	})
+
+	t.Run("legacy aws block", func(t *testing.T) {
+		path := writeConfig(t, `
+aws:
+  family: {name: upbound-provider-aws, package: xpkg.upbound.io/upbound/provider-family-aws, version: v1}
+  services:
+    - {name: provider-aws-s3, package: xpkg.upbound.io/upbound/provider-aws-s3, version: v1}
+`)
+		cfg, err := LoadConfig(path)
+		assert.NoError(t, err)
+		assert.Nil(t, cfg.AWS)
+		assert.Equal(t, []string{"aws"}, cfg.FamilyNames())
+		assert.Equal(t, "provider-aws-s3", cfg.Families["aws"].Services[0].Name)
+	})
+
+	t.Run("aws defined twice", func(t *testing.T) {
+		path := writeConfig(t, `
+aws:
+  family: {name: a, package: b, version: v1}
+families:
+  aws:
+    family: {name: a, package: b, version: v1}
+`)
+		_, err := LoadConfig(path)
+		assert.Error(t, err)
+	})
+
+	t.Run("incomplete family", func(t *testing.T) {
+		cfg := &Config{Families: map[string]ProviderFamily{"azure": {Family: Provider{Name: "upbound-provider-azure"}}}}
+		err := cfg.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "azure family provider")
+	})
+
+	t.Run("incomplete function", func(t *testing.T) {
+		cfg := &Config{Functions: []Provider{{Name: "function-patch-and-transform"}}}
+		err := cfg.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "function at index 0")
+	})
+}
+
+func TestLoadConfigCrossplane(t *testing.T) {
+	path := writeConfig(t, `
+crossplane:
+  version: 1.19.0
+  valuesFiles: [crossplane-values.yaml, /etc/crosslab/values.yaml]
+  values:
+    args: [--debug]
+  set: [replicas=2]
+`)
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.19.0", cfg.Crossplane.Version)
+	assert.Equal(t, []string{filepath.Join(filepath.Dir(path), "crossplane-values.yaml"), "/etc/crosslab/values.yaml"}, cfg.Crossplane.ValuesFiles)
+	assert.Equal(t, []interface{}{"--debug"}, cfg.Crossplane.Values["args"])
+	assert.Equal(t, []string{"replicas=2"}, cfg.Crossplane.Set)
+}
+
+func TestLoadConfigCluster(t *testing.T) {
+	path := writeConfig(t, `
+cluster:
+  kubernetesVersion: "1.31"
+`)
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.31", cfg.Cluster.KubernetesVersion)
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Cluster.NodeImage = "kindest/node:v1.31.6"
+	assert.ErrorContains(t, cfg.Validate(), "both kubernetesVersion and nodeImage")
+}
+
+func TestParsePackageRef(t *testing.T) {
+	tests := []struct {
+		ref     string
+		pkg     string
+		version string
+	}{
+		{ref: "xpkg.upbound.io/upbound/provider-aws-s3:v1", pkg: "xpkg.upbound.io/upbound/provider-aws-s3", version: "v1"},
+		{ref: "localhost:5001/provider-internal:v0.1.0", pkg: "localhost:5001/provider-internal", version: "v0.1.0"},
+		{ref: "localhost:5001/provider-internal", pkg: "localhost:5001/provider-internal", version: ""},
+		{ref: "xpkg.upbound.io/upbound/provider-aws-s3@sha256:abc", pkg: "xpkg.upbound.io/upbound/provider-aws-s3", version: "sha256:abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			pkg, version := ParsePackageRef(tt.ref)
+			assert.Equal(t, tt.pkg, pkg)
+			assert.Equal(t, tt.version, version)
+			assert.Equal(t, tt.ref, Provider{Package: pkg, Version: version}.Ref())
+		})
+	}
+}
+
+func TestPackagesDependencies(t *testing.T) {
+	cfg := &Config{
+		Families: map[string]ProviderFamily{
+			"aws": {
+				Family:   Provider{Name: "upbound-provider-aws", Package: "xpkg.upbound.io/upbound/provider-family-aws", Version: "v1"},
+				Services: []Provider{{Name: "provider-aws-s3", Package: "xpkg.upbound.io/upbound/provider-aws-s3", Version: "v1"}},
+			},
+		},
+		Functions:      []Provider{{Name: "function-go-templating", Package: "xpkg.upbound.io/crossplane-contrib/function-go-templating", Version: "v0.9.2"}},
+		Configurations: []Provider{{Name: "platform-ref-aws", Package: "xpkg.upbound.io/upbound/platform-ref-aws", Version: "v1.4.0"}},
+	}
+
+	packages := cfg.Packages()
+	assert.Len(t, packages, 4)
+	assert.Equal(t, []string{"upbound-provider-aws"}, packages[1].DependsOn)
+	assert.Equal(t, []string{"upbound-provider-aws", "provider-aws-s3", "function-go-templating"}, packages[3].DependsOn)
+	assert.Empty(t, cfg.Families["aws"].Services[0].DependsOn)
+	assert.NoError(t, cfg.Validate())
+
+	cfg.OtherProviders = []Provider{{Name: "provider-helm", Package: "xpkg.upbound.io/upbound/provider-helm", Version: "v0.20.4", DependsOn: []string{"provider-missing"}}}
+	err := cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown package provider-missing")
+
+	cfg.OtherProviders = nil
+	cfg.Functions = append(cfg.Functions, Provider{Name: "provider-aws-s3", Package: "xpkg.upbound.io/crossplane-contrib/function-patch-and-transform", Version: "v0.8.2"})
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "duplicate package name provider-aws-s3")
+}
+
+func TestLoadConfigRuntimeConfig(t *testing.T) {
+	path := writeConfig(t, `
+runtimeConfig:
+  debug: true
+  args: ["--enable-management-policies"]
+otherProviders:
+  - name: provider-helm
+    package: xpkg.upbound.io/upbound/provider-helm
+    version: v0.20.4
+    runtimeConfig:
+      poll: 30s
+      args: ["--sync=1h"]
+      files:
+        - {path: certs/ca.crt, mountPath: /etc/ssl/certs/ca.crt}
+functions:
+  - {name: function-patch-and-transform, package: xpkg.upbound.io/crossplane-contrib/function-patch-and-transform, version: v0.8.2}
+configurations:
+  - {name: platform-ref-aws, package: xpkg.upbound.io/upbound/platform-ref-aws, version: v1.4.0}
+`)
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.NoError(t, cfg.Validate())
+
+	packages := cfg.Packages()
+	helm := packages[0].RuntimeConfig
+	assert.Equal(t, []string{"--debug", "--poll=30s", "--enable-management-policies", "--sync=1h"}, helm.ContainerArgs())
+	assert.Equal(t, filepath.Join(filepath.Dir(path), "certs/ca.crt"), helm.Files[0].Path)
+
+	assert.Equal(t, []string{"--debug", "--enable-management-policies"}, packages[1].RuntimeConfig.ContainerArgs())
+	assert.Nil(t, packages[2].RuntimeConfig)
+
+	cfg.OtherProviders[0].RuntimeConfig.Poll = "often"
+	assert.Error(t, cfg.Validate())
+
+	cfg.OtherProviders[0].RuntimeConfig.Poll = ""
+	cfg.Configurations[0].RuntimeConfig = &RuntimeConfig{Args: []string{"--debug"}}
+	assert.Error(t, cfg.Validate())
+}
+
+func TestMergeRuntimeConfig(t *testing.T) {
+	debug, noDebug := true, false
+	defaults := &RuntimeConfig{Debug: &debug, Poll: "1m", Args: []string{"--enable-management-policies"}}
+
+	merged := mergeRuntimeConfig(defaults, &RuntimeConfig{Debug: &noDebug, Args: []string{"--sync=1h"}})
+	assert.Equal(t, []string{"--poll=1m", "--enable-management-policies", "--sync=1h"}, merged.ContainerArgs())
+
+	merged = mergeRuntimeConfig(defaults, &RuntimeConfig{Poll: "30s"})
+	assert.Equal(t, []string{"--debug", "--poll=30s", "--enable-management-policies"}, merged.ContainerArgs())
+}
+
+func TestValidateInstallOptions(t *testing.T) {
+	path := writeConfig(t, `
+otherProviders:
+  - name: provider-internal
+    package: registry.example.com/crossplane/provider-internal
+    version: v1.0.0
+    packagePullPolicy: Always
+    packagePullSecrets: [registry-example-com]
+    revisionActivationPolicy: Manual
+    revisionHistoryLimit: 3
+    skipDependencyResolution: true
+`)
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, int64(3), *cfg.OtherProviders[0].RevisionHistoryLimit)
+
+	for _, p := range []Provider{
+		{PackagePullPolicy: "Sometimes"},
+		{RevisionActivationPolicy: "manual"},
+		{RevisionHistoryLimit: new(int64)},
+		{PackagePullSecrets: []string{""}},
+	} {
+		if p.RevisionHistoryLimit != nil {
+			*p.RevisionHistoryLimit = -1
+		}
+		assert.Error(t, p.ValidateInstallOptions())
+	}
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CredentialsSourceKind is where the credentials of a ProviderConfig come from
+type CredentialsSourceKind string
+
+const (
+	// AWSProfileSource reads a profile of the AWS shared credentials file
+	AWSProfileSource CredentialsSourceKind = "awsProfile"
+	// FileSource reads a credentials file as is, e.g. a GCP service account key
+	FileSource CredentialsSourceKind = "file"
+	// EnvSource reads environment variables
+	EnvSource CredentialsSourceKind = "env"
+	// AnonymousSource uses fake credentials, for local stand-ins of cloud APIs
+	AnonymousSource CredentialsSourceKind = "anonymous"
+)
+
+// DefaultCredentialsKey is the key of the credentials in the Secret
+const DefaultCredentialsKey = "credentials"
+
+// anonymousCredentials are the fake AWS credentials local stand-ins accept
+const anonymousCredentials = "[default]\naws_access_key_id = test\naws_secret_access_key = test\n"
+
+// ProviderConfig is a ProviderConfig created once its provider is installed,
+// together with the Secret holding its credentials
+type ProviderConfig struct {
+	// Name is the name of the ProviderConfig, e.g. default
+	Name string `yaml:"name"`
+	// APIVersion is the API version of the ProviderConfig kind, e.g. aws.upbound.io/v1beta1
+	APIVersion string `yaml:"apiVersion"`
+	// Kind is the ProviderConfig kind, ProviderConfig by default
+	Kind string `yaml:"kind,omitempty"`
+	// Credentials is where the credentials come from
+	Credentials CredentialsSource `yaml:"credentials"`
+	// Spec holds further spec fields of the ProviderConfig, e.g. endpoint
+	Spec map[string]interface{} `yaml:"spec,omitempty"`
+}
+
+// CredentialsSource is where the credentials of a ProviderConfig come from
+type CredentialsSource struct {
+	// Source is awsProfile, file, env or anonymous
+	Source CredentialsSourceKind `yaml:"source"`
+	// Profile is the AWS profile for the awsProfile source, default by default
+	Profile string `yaml:"profile,omitempty"`
+	// Path is the credentials file for the file source
+	Path string `yaml:"path,omitempty"`
+	// Env lists the environment variables for the env source
+	Env []string `yaml:"env,omitempty"`
+	// SecretName is the name of the Secret, <group>-<name>-credentials by default
+	SecretName string `yaml:"secretName,omitempty"`
+	// SecretKey is the key of the credentials in the Secret
+	SecretKey string `yaml:"secretKey,omitempty"`
+}
+
+// ProviderConfigKind returns the kind of the ProviderConfig
+func (p ProviderConfig) ProviderConfigKind() string {
+	if p.Kind == "" {
+		return "ProviderConfig"
+	}
+	return p.Kind
+}
+
+// Group returns the API group of the ProviderConfig, e.g. aws.upbound.io
+func (p ProviderConfig) Group() string {
+	group, _, _ := strings.Cut(p.APIVersion, "/")
+	return group
+}
+
+// SecretName returns the name of the credentials Secret
+func (p ProviderConfig) SecretName() string {
+	if p.Credentials.SecretName != "" {
+		return p.Credentials.SecretName
+	}
+	prefix, _, _ := strings.Cut(p.Group(), ".")
+	return prefix + "-" + p.Name + "-credentials"
+}
+
+// SecretKey returns the key of the credentials in the Secret
+func (p ProviderConfig) SecretKey() string {
+	if p.Credentials.SecretKey != "" {
+		return p.Credentials.SecretKey
+	}
+	return DefaultCredentialsKey
+}
+
+// Validate checks that the ProviderConfig and its credentials source are complete
+func (p ProviderConfig) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !strings.Contains(p.APIVersion, "/") {
+		return fmt.Errorf("apiVersion must be <group>/<version>, got %q", p.APIVersion)
+	}
+
+	c := p.Credentials
+	switch c.Source {
+	case AWSProfileSource, AnonymousSource:
+	case FileSource:
+		if c.Path == "" {
+			return fmt.Errorf("credentials source file requires a path")
+		}
+	case EnvSource:
+		if len(c.Env) == 0 {
+			return fmt.Errorf("credentials source env requires env variables")
+		}
+	default:
+		return fmt.Errorf("unknown credentials source %q, use awsProfile, file, env or anonymous", c.Source)
+	}
+
+	return nil
+}
+
+// Resolve reads the credentials from their source
+func (c CredentialsSource) Resolve() ([]byte, error) {
+	switch c.Source {
+	case AWSProfileSource:
+		return awsProfileCredentials(c.Profile)
+	case FileSource:
+		raw, err := os.ReadFile(expandHome(c.Path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read credentials file: %v", err)
+		}
+		return raw, nil
+	case EnvSource:
+		return envCredentials(c.Env)
+	case AnonymousSource:
+		return []byte(anonymousCredentials), nil
+	}
+
+	return nil, fmt.Errorf("unknown credentials source %q", c.Source)
+}
+
+// awsProfileCredentials reads a profile of the AWS shared credentials file and
+// returns it as the default profile, as providers read the default profile
+func awsProfileCredentials(profile string) ([]byte, error) {
+	if profile == "" {
+		profile = "default"
+	}
+
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		path = "~/.aws/credentials"
+	}
+	raw, err := os.ReadFile(expandHome(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AWS credentials: %v", err)
+	}
+
+	values := map[string]string{}
+	found := false
+	section := ""
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			found = found || section == profile
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("AWS profile %s not found in %s", profile, path)
+	}
+	return iniProfile(values), nil
+}
+
+// envCredentials returns the environment variables as the default profile of
+// an AWS style credentials file, with lower case keys, e.g. aws_access_key_id
+func envCredentials(names []string) ([]byte, error) {
+	values := map[string]string{}
+	for _, name := range names {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", name)
+		}
+		values[strings.ToLower(name)] = value
+	}
+	return iniProfile(values), nil
+}
+
+// iniProfile renders values as the default profile of an INI file
+func iniProfile(values map[string]string) []byte {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("[default]\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s = %s\n", key, values[key])
+	}
+	return []byte(b.String())
+}
+
+// expandHome replaces a leading ~ with the home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
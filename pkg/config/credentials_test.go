@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfigProviderConfigs(t *testing.T) {
+	path := writeConfig(t, `
+providerConfigs:
+  - name: default
+    apiVersion: aws.upbound.io/v1beta1
+    credentials: {source: awsProfile, profile: sandbox}
+  - name: local
+    apiVersion: aws.upbound.io/v1beta1
+    credentials: {source: anonymous}
+    spec:
+      endpoint: {url: {type: Static, static: "http://localstack:4566"}}
+`)
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.NoError(t, cfg.Validate())
+	assert.Len(t, cfg.ProviderConfigs, 2)
+
+	pc := cfg.ProviderConfigs[0]
+	assert.Equal(t, "ProviderConfig", pc.ProviderConfigKind())
+	assert.Equal(t, "aws.upbound.io", pc.Group())
+	assert.Equal(t, "aws-default-credentials", pc.SecretName())
+	assert.Equal(t, DefaultCredentialsKey, pc.SecretKey())
+	assert.Contains(t, cfg.ProviderConfigs[1].Spec, "endpoint")
+
+	for _, content := range []string{
+		"providerConfigs:\n- {apiVersion: aws.upbound.io/v1beta1, credentials: {source: anonymous}}\n",
+		"providerConfigs:\n- {name: default, apiVersion: v1beta1, credentials: {source: anonymous}}\n",
+		"providerConfigs:\n- {name: default, apiVersion: aws.upbound.io/v1beta1, credentials: {source: vault}}\n",
+		"providerConfigs:\n- {name: default, apiVersion: aws.upbound.io/v1beta1, credentials: {source: file}}\n",
+		"providerConfigs:\n- {name: default, apiVersion: aws.upbound.io/v1beta1, credentials: {source: env}}\n",
+	} {
+		cfg, err := LoadConfig(writeConfig(t, content))
+		assert.NoError(t, err)
+		assert.Error(t, cfg.Validate(), content)
+	}
+}
+
+func TestResolveCredentials(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("aws profile", func(t *testing.T) {
+		path := filepath.Join(dir, "credentials")
+		assert.NoError(t, os.WriteFile(path, []byte(`[default]
+aws_access_key_id = AKIADEFAULT
+aws_secret_access_key = default
+
+# sandbox account
+[sandbox]
+aws_secret_access_key = sandbox
+aws_access_key_id = AKIASANDBOX
+`), 0600))
+		t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+
+		raw, err := CredentialsSource{Source: AWSProfileSource, Profile: "sandbox"}.Resolve()
+		assert.NoError(t, err)
+		assert.Equal(t, "[default]\naws_access_key_id = AKIASANDBOX\naws_secret_access_key = sandbox\n", string(raw))
+
+		_, err = CredentialsSource{Source: AWSProfileSource, Profile: "missing"}.Resolve()
+		assert.Error(t, err)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(dir, "gcp.json")
+		assert.NoError(t, os.WriteFile(path, []byte(`{"type": "service_account"}`), 0600))
+
+		raw, err := CredentialsSource{Source: FileSource, Path: path}.Resolve()
+		assert.NoError(t, err)
+		assert.Equal(t, `{"type": "service_account"}`, string(raw))
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("AWS_ACCESS_KEY_ID", "AKIAENV")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "env")
+
+		raw, err := CredentialsSource{Source: EnvSource, Env: []string{"AWS_SECRET_ACCESS_KEY", "AWS_ACCESS_KEY_ID"}}.Resolve()
+		assert.NoError(t, err)
+		assert.Equal(t, "[default]\naws_access_key_id = AKIAENV\naws_secret_access_key = env\n", string(raw))
+
+		_, err = CredentialsSource{Source: EnvSource, Env: []string{"CROSSLAB_UNSET_VARIABLE"}}.Resolve()
+		assert.Error(t, err)
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		raw, err := CredentialsSource{Source: AnonymousSource}.Resolve()
+		assert.NoError(t, err)
+		assert.Contains(t, string(raw), "aws_access_key_id = test")
+	})
+}
@@ -11,6 +11,8 @@ import (
 // Initializer handles configuration initialization
 type Initializer struct {
 	OutputDir string
+	// NodeImage is set as the image of every node of the Kind configuration
+	NodeImage string
 }
 
 // NewInitializer creates a new configuration initializer
@@ -43,6 +45,9 @@ func (i *Initializer) Initialize() error {
 // createKindConfig creates the Kind cluster configuration file
 func (i *Initializer) createKindConfig() error {
 	kindConfig := DefaultKindConfig()
+	if i.NodeImage != "" {
+		kindConfig = DefaultKindConfigWithImage(i.NodeImage)
+	}
 	kindConfigPath := filepath.Join(i.OutputDir, "kind-config.yaml")
 
 	if err := writeYAMLFile(kindConfigPath, kindConfig); err != nil {
@@ -85,42 +90,44 @@ func writeYAMLFile(path string, data interface{}) error {
 }
 
 // DefaultProvidersConfig returns a default providers configuration
-func DefaultProvidersConfig() map[string]interface{} {
-	return map[string]interface{}{
-		"aws": map[string]interface{}{
-			"family": map[string]string{
-				"name":    "upbound-provider-aws",
-				"package": "xpkg.upbound.io/upbound/provider-family-aws",
-				"version": "v1",
-			},
-			"services": []map[string]string{
-				{
-					"name":    "provider-aws-iam",
-					"package": "xpkg.upbound.io/upbound/provider-aws-iam",
-					"version": "v1",
-				},
-				{
-					"name":    "provider-aws-s3",
-					"package": "xpkg.upbound.io/upbound/provider-aws-s3",
-					"version": "v1",
+func DefaultProvidersConfig() *Config {
+	return &Config{
+		Families: map[string]ProviderFamily{
+			"aws": {
+				Family: Provider{
+					Name:    "upbound-provider-aws",
+					Package: "xpkg.upbound.io/upbound/provider-family-aws",
+					Version: "v1",
 				},
-				{
-					"name":    "provider-aws-rds",
-					"package": "xpkg.upbound.io/upbound/provider-aws-rds",
-					"version": "v1",
+				Services: []Provider{
+					{
+						Name:    "provider-aws-iam",
+						Package: "xpkg.upbound.io/upbound/provider-aws-iam",
+						Version: "v1",
+					},
+					{
+						Name:    "provider-aws-s3",
+						Package: "xpkg.upbound.io/upbound/provider-aws-s3",
+						Version: "v1",
+					},
+					{
+						Name:    "provider-aws-rds",
+						Package: "xpkg.upbound.io/upbound/provider-aws-rds",
+						Version: "v1",
+					},
 				},
 			},
 		},
-		"otherProviders": []map[string]string{
+		OtherProviders: []Provider{
 			{
-				"name":    "provider-helm",
-				"package": "xpkg.upbound.io/upbound/provider-helm",
-				"version": "v0.20.4",
+				Name:    "provider-helm",
+				Package: "xpkg.upbound.io/upbound/provider-helm",
+				Version: "v0.20.4",
 			},
 			{
-				"name":    "provider-kubernetes",
-				"package": "xpkg.upbound.io/upbound/provider-kubernetes",
-				"version": "v0.16.3",
+				Name:    "provider-kubernetes",
+				Package: "xpkg.upbound.io/upbound/provider-kubernetes",
+				Version: "v0.16.3",
 			},
 		},
 	}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	// DefaultLocalStackImage is the image of the AWS stand-in
+	DefaultLocalStackImage = "localstack/localstack:4.0"
+	// DefaultLocalStackPort is the port the AWS stand-in serves every service on
+	DefaultLocalStackPort = 4566
+	// awsProviderConfigAPIVersion is the API version of the Upbound AWS ProviderConfig
+	awsProviderConfigAPIVersion = "aws.upbound.io/v1beta1"
+)
+
+// LocalStackConfig deploys LocalStack, or another AWS stand-in, into the
+// cluster and points the AWS providers at it
+type LocalStackConfig struct {
+	// Enabled deploys the stand-in and generates the AWS ProviderConfig
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Image is the stand-in image, localstack/localstack:4.0 by default
+	Image string `yaml:"image,omitempty"`
+	// Port is the port of the stand-in, 4566 by default
+	Port int `yaml:"port,omitempty"`
+	// Env sets environment variables of the stand-in, e.g. SERVICES
+	Env map[string]string `yaml:"env,omitempty"`
+	// ProviderConfigName is the name of the generated ProviderConfig, default by default
+	ProviderConfigName string `yaml:"providerConfigName,omitempty"`
+}
+
+// ImageOrDefault returns the stand-in image
+func (l LocalStackConfig) ImageOrDefault() string {
+	if l.Image == "" {
+		return DefaultLocalStackImage
+	}
+	return l.Image
+}
+
+// PortOrDefault returns the port of the stand-in
+func (l LocalStackConfig) PortOrDefault() int {
+	if l.Port == 0 {
+		return DefaultLocalStackPort
+	}
+	return l.Port
+}
+
+// LocalStackServices returns the AWS service names of the aws family's
+// service providers, e.g. s3 for xpkg.upbound.io/upbound/provider-aws-s3
+func (c *Config) LocalStackServices() []string {
+	var services []string
+	for _, service := range c.Families["aws"].Services {
+		name := strings.TrimPrefix(path.Base(service.Package), "provider-aws-")
+		services = appendUnique(services, name)
+	}
+	return services
+}
+
+// LocalStackProviderConfig returns the AWS ProviderConfig that sends the
+// requests of every aws family service to the stand-in at endpoint, with
+// anonymous credentials
+func (c *Config) LocalStackProviderConfig(endpoint string) ProviderConfig {
+	name := c.LocalStack.ProviderConfigName
+	if name == "" {
+		name = "default"
+	}
+
+	services := make([]interface{}, 0, len(c.LocalStackServices()))
+	for _, s := range c.LocalStackServices() {
+		services = append(services, s)
+	}
+
+	return ProviderConfig{
+		Name:        name,
+		APIVersion:  awsProviderConfigAPIVersion,
+		Credentials: CredentialsSource{Source: AnonymousSource},
+		Spec: map[string]interface{}{
+			"endpoint": map[string]interface{}{
+				"hostnameImmutable": true,
+				"services":          services,
+				"url": map[string]interface{}{
+					"type":   "Static",
+					"static": endpoint,
+				},
+			},
+			"skip_credentials_validation": true,
+			"skip_metadata_api_check":     true,
+			"skip_region_validation":      true,
+			"skip_requesting_account_id":  true,
+			"s3_use_path_style":           true,
+		},
+	}
+}
+
+// ProviderConfigsWithLocalStack returns the configured ProviderConfigs, with
+// the LocalStack ProviderConfig replacing a configured one of the same name
+func (c *Config) ProviderConfigsWithLocalStack(endpoint string) []ProviderConfig {
+	localStack := c.LocalStackProviderConfig(endpoint)
+
+	providerConfigs := []ProviderConfig{}
+	for _, pc := range c.ProviderConfigs {
+		if pc.APIVersion == localStack.APIVersion && pc.ProviderConfigKind() == localStack.ProviderConfigKind() && pc.Name == localStack.Name {
+			continue
+		}
+		providerConfigs = append(providerConfigs, pc)
+	}
+	return append(providerConfigs, localStack)
+}
+
+// validateLocalStack checks that LocalStack has AWS services to stand in for
+func (c *Config) validateLocalStack() error {
+	if !c.LocalStack.Enabled {
+		return nil
+	}
+	if len(c.LocalStackServices()) == 0 {
+		return fmt.Errorf("localstack requires the aws family with at least one service provider")
+	}
+	if c.LocalStack.Port < 0 || c.LocalStack.Port > 65535 {
+		return fmt.Errorf("localstack port %d is out of range", c.LocalStack.Port)
+	}
+	return nil
+}
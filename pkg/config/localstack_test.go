@@ -0,0 +1,43 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStackProviderConfigs(t *testing.T) {
+	path := writeConfig(t, `
+families:
+  aws:
+    family: {name: upbound-provider-aws, package: xpkg.upbound.io/upbound/provider-family-aws, version: v1}
+    services:
+      - {name: provider-aws-s3, package: xpkg.upbound.io/upbound/provider-aws-s3, version: v1}
+      - {name: sqs, package: xpkg.upbound.io/upbound/provider-aws-sqs, version: v1}
+providerConfigs:
+  - {name: default, apiVersion: aws.upbound.io/v1beta1, credentials: {source: awsProfile}}
+  - {name: default, apiVersion: helm.crossplane.io/v1beta1, credentials: {source: anonymous}}
+localstack:
+  enabled: true
+`)
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, []string{"s3", "sqs"}, cfg.LocalStackServices())
+
+	providerConfigs := cfg.ProviderConfigsWithLocalStack("http://localstack.crossplane-system.svc.cluster.local:4566")
+	assert.Len(t, providerConfigs, 2)
+	assert.Equal(t, "helm.crossplane.io/v1beta1", providerConfigs[0].APIVersion)
+
+	localStack := providerConfigs[1]
+	assert.Equal(t, "default", localStack.Name)
+	assert.Equal(t, AnonymousSource, localStack.Credentials.Source)
+	endpoint := localStack.Spec["endpoint"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"s3", "sqs"}, endpoint["services"])
+	assert.Equal(t, "http://localstack.crossplane-system.svc.cluster.local:4566", endpoint["url"].(map[string]interface{})["static"])
+
+	t.Run("without aws services", func(t *testing.T) {
+		cfg := &Config{LocalStack: LocalStackConfig{Enabled: true}}
+		assert.Error(t, cfg.Validate())
+	})
+}
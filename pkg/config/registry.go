@@ -0,0 +1,167 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// RegistryAuthSource is where the credentials of a private registry come from
+type RegistryAuthSource string
+
+const (
+	// DockerConfigSource reads the local Docker config, including credential helpers
+	DockerConfigSource RegistryAuthSource = "dockerConfig"
+	// DockerConfigFileSource reads the registry's entry of a Docker config JSON file
+	DockerConfigFileSource RegistryAuthSource = "file"
+	// RegistryEnvSource reads a username and password from environment variables
+	RegistryEnvSource RegistryAuthSource = "env"
+)
+
+const (
+	// DefaultRegistryUsernameEnv is the default username variable of the env source
+	DefaultRegistryUsernameEnv = "REGISTRY_USERNAME"
+	// DefaultRegistryPasswordEnv is the default password variable of the env source
+	DefaultRegistryPasswordEnv = "REGISTRY_PASSWORD"
+)
+
+// RegistryAuth is a pull secret for a private registry, created in the
+// Crossplane namespace and referenced by the Crossplane release and by every
+// package from that registry
+type RegistryAuth struct {
+	// Registry is the registry host, e.g. registry.example.com
+	Registry string `yaml:"registry"`
+	// Name is the name of the Secret, derived from the registry by default
+	Name string `yaml:"name,omitempty"`
+	// Source is dockerConfig (default), file or env
+	Source RegistryAuthSource `yaml:"source,omitempty"`
+	// Path is the Docker config JSON file of the file source
+	Path string `yaml:"path,omitempty"`
+	// UsernameEnv and PasswordEnv are the variables of the env source
+	UsernameEnv string `yaml:"usernameEnv,omitempty"`
+	PasswordEnv string `yaml:"passwordEnv,omitempty"`
+}
+
+// SecretName returns the name of the pull secret, e.g. registry-example-com
+func (r RegistryAuth) SecretName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return strings.NewReplacer(".", "-", ":", "-").Replace(strings.ToLower(r.Registry))
+}
+
+// Matches reports whether a package is pulled from the registry
+func (r RegistryAuth) Matches(pkg string) bool {
+	host, _, found := strings.Cut(pkg, "/")
+	return found && host == r.Registry
+}
+
+// Validate checks that the registry and its credentials source are complete
+func (r RegistryAuth) Validate() error {
+	if r.Registry == "" {
+		return fmt.Errorf("registry is required")
+	}
+	switch r.Source {
+	case "", DockerConfigSource, RegistryEnvSource:
+	case DockerConfigFileSource:
+		if r.Path == "" {
+			return fmt.Errorf("source file requires a path")
+		}
+	default:
+		return fmt.Errorf("unknown source %q, use dockerConfig, file or env", r.Source)
+	}
+	return nil
+}
+
+// DockerConfigJSON returns the .dockerconfigjson content of the pull secret
+func (r RegistryAuth) DockerConfigJSON() ([]byte, error) {
+	switch r.Source {
+	case "", DockerConfigSource:
+		registry, err := name.NewRegistry(r.Registry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid registry %s: %v", r.Registry, err)
+		}
+		auth, err := authn.DefaultKeychain.Resolve(registry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Docker config: %v", err)
+		}
+		cfg, err := auth.Authorization()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get credentials of %s: %v", r.Registry, err)
+		}
+		if *cfg == (authn.AuthConfig{}) {
+			return nil, fmt.Errorf("no credentials for %s in the Docker config, run 'docker login %s'", r.Registry, r.Registry)
+		}
+		return dockerConfigJSON(r.Registry, *cfg)
+
+	case DockerConfigFileSource:
+		raw, err := os.ReadFile(expandHome(r.Path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Docker config file: %v", err)
+		}
+		var file struct {
+			Auths map[string]authn.AuthConfig `json:"auths"`
+		}
+		if err := json.Unmarshal(raw, &file); err != nil {
+			return nil, fmt.Errorf("%s is not a Docker config file: %v", r.Path, err)
+		}
+		// Only the entry of this registry goes into the secret
+		cfg, ok := file.Auths[r.Registry]
+		if !ok {
+			cfg, ok = file.Auths["https://"+r.Registry]
+		}
+		if !ok || cfg.Auth == "" && cfg.Username == "" {
+			return nil, fmt.Errorf("no credentials for %s in %s", r.Registry, r.Path)
+		}
+		return dockerConfigJSON(r.Registry, cfg)
+
+	case RegistryEnvSource:
+		usernameEnv, passwordEnv := r.UsernameEnv, r.PasswordEnv
+		if usernameEnv == "" {
+			usernameEnv = DefaultRegistryUsernameEnv
+		}
+		if passwordEnv == "" {
+			passwordEnv = DefaultRegistryPasswordEnv
+		}
+		username, ok := os.LookupEnv(usernameEnv)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", usernameEnv)
+		}
+		password, ok := os.LookupEnv(passwordEnv)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", passwordEnv)
+		}
+		return dockerConfigJSON(r.Registry, authn.AuthConfig{Username: username, Password: password})
+	}
+
+	return nil, fmt.Errorf("unknown source %q", r.Source)
+}
+
+// dockerConfigJSON renders a Docker config with the credentials of a single registry
+func dockerConfigJSON(registry string, cfg authn.AuthConfig) ([]byte, error) {
+	if cfg.Auth == "" && cfg.Username != "" {
+		cfg.Auth = base64.StdEncoding.EncodeToString([]byte(cfg.Username + ":" + cfg.Password))
+	}
+	return json.Marshal(map[string]interface{}{
+		"auths": map[string]authn.AuthConfig{registry: cfg},
+	})
+}
+
+// withPullSecrets adds the pull secrets of the registry a package is pulled from
+func (c *Config) withPullSecrets(p Provider) Provider {
+	secrets := append([]string{}, p.PackagePullSecrets...)
+	for _, auth := range c.RegistryAuth {
+		if auth.Matches(p.Package) {
+			secrets = appendUnique(secrets, auth.SecretName())
+		}
+	}
+	if len(secrets) > 0 {
+		p.PackagePullSecrets = secrets
+	}
+	return p
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfigRegistryAuth(t *testing.T) {
+	path := writeConfig(t, `
+registryAuth:
+  - registry: registry.example.com
+  - {registry: "localhost:5000", name: local, source: env}
+otherProviders:
+  - {name: provider-internal, package: registry.example.com/crossplane/provider-internal, version: v1.0.0, packagePullSecrets: [extra]}
+  - {name: provider-helm, package: xpkg.upbound.io/upbound/provider-helm, version: v0.20.4}
+configurations:
+  - {name: platform, package: localhost:5000/platform, version: v0.1.0}
+`)
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, []string{"registry-example-com", "local"}, cfg.Crossplane.ImagePullSecrets)
+
+	packages := cfg.Packages()
+	assert.Equal(t, []string{"extra", "registry-example-com"}, packages[0].PackagePullSecrets)
+	assert.Empty(t, packages[1].PackagePullSecrets)
+	assert.Equal(t, []string{"local"}, packages[2].PackagePullSecrets)
+	assert.Equal(t, []string{"extra"}, cfg.OtherProviders[0].PackagePullSecrets)
+
+	cfg.RegistryAuth = append(cfg.RegistryAuth, RegistryAuth{Registry: "ghcr.io", Source: DockerConfigFileSource})
+	assert.Error(t, cfg.Validate())
+}
+
+func TestDockerConfigJSON(t *testing.T) {
+	dir := t.TempDir()
+	dockerConfig := `{"auths":{"registry.example.com":{"auth":"dXNlcjpzZWNyZXQ="},"other.example.com":{"auth":"b3RoZXI6dG9rZW4="},"quay.io":{}}}`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(dockerConfig), 0600))
+
+	t.Run("docker config", func(t *testing.T) {
+		t.Setenv("DOCKER_CONFIG", dir)
+
+		raw, err := RegistryAuth{Registry: "registry.example.com"}.DockerConfigJSON()
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"auths":{"registry.example.com":{"username":"user","password":"secret","auth":"dXNlcjpzZWNyZXQ="}}}`, string(raw))
+
+		_, err = RegistryAuth{Registry: "ghcr.io"}.DockerConfigJSON()
+		assert.Error(t, err)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(dir, "config.json")
+		raw, err := RegistryAuth{Registry: "registry.example.com", Source: DockerConfigFileSource, Path: path}.DockerConfigJSON()
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"auths":{"registry.example.com":{"username":"user","password":"secret","auth":"dXNlcjpzZWNyZXQ="}}}`, string(raw))
+
+		_, err = RegistryAuth{Registry: "quay.io", Source: DockerConfigFileSource, Path: path}.DockerConfigJSON()
+		assert.Error(t, err)
+		_, err = RegistryAuth{Registry: "docker.io", Source: DockerConfigFileSource, Path: path}.DockerConfigJSON()
+		assert.Error(t, err)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("REGISTRY_USERNAME", "user")
+		t.Setenv("REGISTRY_PASSWORD", "secret")
+
+		raw, err := RegistryAuth{Registry: "registry.example.com", Source: RegistryEnvSource}.DockerConfigJSON()
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"auths":{"registry.example.com":{"username":"user","password":"secret","auth":"dXNlcjpzZWNyZXQ="}}}`, string(raw))
+	})
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// RuntimeConfig configures the Deployment of a provider or function through
+// a pkg.crossplane.io DeploymentRuntimeConfig
+type RuntimeConfig struct {
+	// Debug adds the --debug argument; a package can set it to false to
+	// override the default runtime configuration
+	Debug *bool `yaml:"debug,omitempty"`
+	// Poll sets the --poll interval, e.g. 1m
+	Poll string `yaml:"poll,omitempty"`
+	// MaxReconcileRate sets the --max-reconcile-rate argument
+	MaxReconcileRate int `yaml:"maxReconcileRate,omitempty"`
+	// Args are further arguments of the package runtime container
+	Args []string `yaml:"args,omitempty"`
+	// Resources are the resource requests and limits of the package runtime container
+	Resources map[string]interface{} `yaml:"resources,omitempty"`
+	// Files are local files mounted into the package runtime container
+	Files []RuntimeFile `yaml:"files,omitempty"`
+}
+
+// RuntimeFile is a local file mounted into the package runtime container
+type RuntimeFile struct {
+	// Path is the local file, relative to the configuration file
+	Path string `yaml:"path"`
+	// MountPath is the path of the file in the container
+	MountPath string `yaml:"mountPath"`
+}
+
+// ContainerArgs returns the arguments of the package runtime container
+func (r RuntimeConfig) ContainerArgs() []string {
+	var args []string
+	if r.Debug != nil && *r.Debug {
+		args = append(args, "--debug")
+	}
+	if r.Poll != "" {
+		args = append(args, "--poll="+r.Poll)
+	}
+	if r.MaxReconcileRate > 0 {
+		args = append(args, fmt.Sprintf("--max-reconcile-rate=%d", r.MaxReconcileRate))
+	}
+	return append(args, r.Args...)
+}
+
+// Validate checks the runtime arguments and files
+func (r RuntimeConfig) Validate() error {
+	if r.Poll != "" {
+		if _, err := time.ParseDuration(r.Poll); err != nil {
+			return fmt.Errorf("invalid poll interval %q: %v", r.Poll, err)
+		}
+	}
+	if r.MaxReconcileRate < 0 {
+		return fmt.Errorf("maxReconcileRate must not be negative")
+	}
+	for i, f := range r.Files {
+		if f.Path == "" || !filepath.IsAbs(f.MountPath) {
+			return fmt.Errorf("file at index %d needs a path and an absolute mountPath", i)
+		}
+	}
+	return nil
+}
+
+// mergeRuntimeConfig returns the default runtime configuration overridden by
+// the package's: arguments and files are appended, other fields replaced
+func mergeRuntimeConfig(defaults, override *RuntimeConfig) *RuntimeConfig {
+	if defaults == nil {
+		return override
+	}
+	if override == nil {
+		merged := *defaults
+		return &merged
+	}
+
+	merged := *defaults
+	if override.Debug != nil {
+		merged.Debug = override.Debug
+	}
+	if override.Poll != "" {
+		merged.Poll = override.Poll
+	}
+	if override.MaxReconcileRate != 0 {
+		merged.MaxReconcileRate = override.MaxReconcileRate
+	}
+	if override.Resources != nil {
+		merged.Resources = override.Resources
+	}
+	merged.Args = append(append([]string{}, defaults.Args...), override.Args...)
+	merged.Files = append(append([]RuntimeFile{}, defaults.Files...), override.Files...)
+	return &merged
+}
+
+// resolveFiles makes the file paths relative to dir absolute
+func (r *RuntimeConfig) resolveFiles(dir string) {
+	if r == nil {
+		return
+	}
+	for i, f := range r.Files {
+		path := expandHome(f.Path)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		r.Files[i].Path = path
+	}
+}
@@ -4,6 +4,16 @@ import (
 	kindconfigv1alpha4 "sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
 )
 
+// DefaultKindConfigWithImage returns the default Kind cluster configuration
+// with every node running the given node image
+func DefaultKindConfigWithImage(image string) *kindconfigv1alpha4.Cluster {
+	cfg := DefaultKindConfig()
+	for i := range cfg.Nodes {
+		cfg.Nodes[i].Image = image
+	}
+	return cfg
+}
+
 // DefaultKindConfig returns a default Kind cluster configuration
 func DefaultKindConfig() *kindconfigv1alpha4.Cluster {
 	return &kindconfigv1alpha4.Cluster{
@@ -0,0 +1,330 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+	"github.com/kanzifucius/crosslab/pkg/kube"
+
+	"gopkg.in/yaml.v3"
+	kindconfigv1alpha4 "sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+const (
+	// minInotifyWatches and minInotifyInstances are the inotify limits kind
+	// recommends for multi-node clusters
+	minInotifyWatches   = 524288
+	minInotifyInstances = 512
+)
+
+// commandRunner runs a command and returns its trimmed output
+type commandRunner func(ctx context.Context, name string, args ...string) (string, error)
+
+// runCommand runs a command on the host
+func runCommand(ctx context.Context, name string, args ...string) (string, error) {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil && len(out) > 0 {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// DockerCheck checks that the Docker daemon is running
+type DockerCheck struct {
+	run commandRunner
+}
+
+// NewDockerCheck creates a Docker check
+func NewDockerCheck() *DockerCheck {
+	return &DockerCheck{run: runCommand}
+}
+
+// Name implements Check
+func (c *DockerCheck) Name() string {
+	return "Docker"
+}
+
+// Run implements Check
+func (c *DockerCheck) Run(ctx context.Context) Result {
+	version, err := c.run(ctx, "docker", "info", "--format", "{{.ServerVersion}}")
+	if err != nil {
+		return Fail("Install Docker and start Docker Desktop or the docker service, then check that 'docker info' works without sudo",
+			"Docker is not running: %v", err)
+	}
+	return OK("Docker %s is running", version)
+}
+
+// PortCheck checks that the host ports of the kind configuration are free
+type PortCheck struct {
+	kindConfigPath string
+	run            commandRunner
+}
+
+// NewPortCheck creates a check of the host ports mapped in a kind
+// configuration, or in the default configuration if it does not exist
+func NewPortCheck(kindConfigPath string) *PortCheck {
+	return &PortCheck{kindConfigPath: kindConfigPath, run: runCommand}
+}
+
+// Name implements Check
+func (c *PortCheck) Name() string {
+	return "Host ports"
+}
+
+// Run implements Check
+func (c *PortCheck) Run(ctx context.Context) Result {
+	cfg := config.DefaultKindConfig()
+	if config.FileExists(c.kindConfigPath) {
+		var err error
+		if cfg, err = loadKindConfig(c.kindConfigPath); err != nil {
+			return Warn("Fix the Kind configuration first", "cannot read the port mappings: %v", err)
+		}
+	}
+
+	kindPorts := c.kindNodePorts(ctx)
+
+	var ports, busy, held []string
+	for _, node := range cfg.Nodes {
+		for _, mapping := range node.ExtraPortMappings {
+			if mapping.HostPort == 0 {
+				continue
+			}
+			address := net.JoinHostPort(mapping.ListenAddress, strconv.Itoa(int(mapping.HostPort)))
+			ports = append(ports, strconv.Itoa(int(mapping.HostPort)))
+
+			listener, err := net.Listen("tcp", address)
+			if err != nil {
+				if kindPorts[strconv.Itoa(int(mapping.HostPort))] {
+					held = append(held, strconv.Itoa(int(mapping.HostPort)))
+				} else {
+					busy = append(busy, strconv.Itoa(int(mapping.HostPort)))
+				}
+				continue
+			}
+			listener.Close()
+		}
+	}
+
+	if len(busy) > 0 {
+		return Fail(fmt.Sprintf("Stop the process using the port (e.g. 'lsof -i :%s') or change the hostPort of the extraPortMappings in %s", busy[0], c.kindConfigPath),
+			"port(s) %s already in use", strings.Join(busy, ", "))
+	}
+	if len(held) > 0 {
+		return Warn("Delete the running cluster before creating another one with this configuration",
+			"port(s) %s are used by a running kind cluster", strings.Join(held, ", "))
+	}
+	if len(ports) == 0 {
+		return OK("no host ports are mapped")
+	}
+	return OK("port(s) %s are free", strings.Join(ports, ", "))
+}
+
+// kindNodePorts returns the host ports published by the node containers of
+// running kind clusters. It is empty when Docker cannot be queried.
+func (c *PortCheck) kindNodePorts(ctx context.Context) map[string]bool {
+	ports := map[string]bool{}
+	out, err := c.run(ctx, "docker", "ps", "--filter", "label=io.x-k8s.kind.cluster", "--format", "{{.Ports}}")
+	if err != nil {
+		return ports
+	}
+
+	// Each mapping reads e.g. 0.0.0.0:80->80/tcp or [::]:80->80/tcp
+	for _, line := range strings.Split(out, "\n") {
+		for _, mapping := range strings.Split(line, ",") {
+			host, _, found := strings.Cut(strings.TrimSpace(mapping), "->")
+			if !found {
+				continue
+			}
+			if i := strings.LastIndex(host, ":"); i >= 0 {
+				ports[host[i+1:]] = true
+			}
+		}
+	}
+	return ports
+}
+
+// InotifyCheck checks that the inotify limits are high enough for multi-node clusters
+type InotifyCheck struct {
+	procDir string
+}
+
+// NewInotifyCheck creates an inotify limits check
+func NewInotifyCheck() *InotifyCheck {
+	return &InotifyCheck{procDir: "/proc/sys/fs/inotify"}
+}
+
+// Name implements Check
+func (c *InotifyCheck) Name() string {
+	return "inotify limits"
+}
+
+// Run implements Check
+func (c *InotifyCheck) Run(ctx context.Context) Result {
+	if runtime.GOOS != "linux" {
+		return OK("not applicable on %s", runtime.GOOS)
+	}
+
+	var low []string
+	for _, limit := range []struct {
+		name string
+		min  int
+	}{
+		{"max_user_watches", minInotifyWatches},
+		{"max_user_instances", minInotifyInstances},
+	} {
+		raw, err := os.ReadFile(filepath.Join(c.procDir, limit.name))
+		if err != nil {
+			return Warn("", "cannot read fs.inotify.%s: %v", limit.name, err)
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+		if err != nil {
+			return Warn("", "cannot parse fs.inotify.%s: %v", limit.name, err)
+		}
+		if value < limit.min {
+			low = append(low, fmt.Sprintf("fs.inotify.%s=%d (want %d)", limit.name, value, limit.min))
+		}
+	}
+
+	if len(low) > 0 {
+		return Warn(fmt.Sprintf("Raise the limits with 'sudo sysctl fs.inotify.max_user_watches=%d fs.inotify.max_user_instances=%d' and persist them in /etc/sysctl.conf", minInotifyWatches, minInotifyInstances),
+			"limits too low for multi-node clusters: %s", strings.Join(low, ", "))
+	}
+	return OK("limits are high enough for multi-node clusters")
+}
+
+// KubeconfigCheck checks that a kubeconfig with the selected context exists
+type KubeconfigCheck struct {
+	factory *kube.ClientFactory
+}
+
+// NewKubeconfigCheck creates a kubeconfig check for the context of a client factory
+func NewKubeconfigCheck(factory *kube.ClientFactory) *KubeconfigCheck {
+	return &KubeconfigCheck{factory: factory}
+}
+
+// Name implements Check
+func (c *KubeconfigCheck) Name() string {
+	return "Kubeconfig"
+}
+
+// Run implements Check
+func (c *KubeconfigCheck) Run(ctx context.Context) Result {
+	const hint = "Run 'crosslab cluster create' to create a cluster, or 'crosslab cluster kubeconfig --name <cluster>' to export an existing one"
+
+	cfg, err := c.factory.RawConfig()
+	if err != nil {
+		return Fail(hint, "%v", err)
+	}
+	if len(cfg.Contexts) == 0 {
+		return Warn(hint, "no kubeconfig contexts found")
+	}
+	if cfg.CurrentContext == "" {
+		return Warn("Select a context with --context or 'kubectl config use-context <context>'", "no current context is set")
+	}
+	if _, ok := cfg.Contexts[cfg.CurrentContext]; !ok {
+		return Fail(hint, "context %s does not exist", cfg.CurrentContext)
+	}
+	return OK("using context %s", cfg.CurrentContext)
+}
+
+// KindConfigCheck checks that the kind configuration file exists and is valid
+type KindConfigCheck struct {
+	path string
+}
+
+// NewKindConfigCheck creates a check of a kind configuration file
+func NewKindConfigCheck(path string) *KindConfigCheck {
+	return &KindConfigCheck{path: path}
+}
+
+// Name implements Check
+func (c *KindConfigCheck) Name() string {
+	return "Kind configuration"
+}
+
+// Run implements Check
+func (c *KindConfigCheck) Run(ctx context.Context) Result {
+	if !config.FileExists(c.path) {
+		return Fail("Run 'crosslab init' to create it", "%s not found", c.path)
+	}
+
+	cfg, err := loadKindConfig(c.path)
+	if err != nil {
+		return Fail(fmt.Sprintf("Fix %s or recreate it with 'crosslab init'", c.path), "%v", err)
+	}
+	if cfg.Kind != "Cluster" || cfg.APIVersion != "kind.x-k8s.io/v1alpha4" {
+		return Fail(fmt.Sprintf("Set 'kind: Cluster' and 'apiVersion: kind.x-k8s.io/v1alpha4' in %s", c.path),
+			"%s is not a kind.x-k8s.io/v1alpha4 Cluster", c.path)
+	}
+	return OK("%s defines %d node(s)", c.path, len(cfg.Nodes))
+}
+
+// loadKindConfig reads a kind configuration file, rejecting unknown fields
+func loadKindConfig(path string) (*kindconfigv1alpha4.Cluster, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %v", path, err)
+	}
+
+	cfg := &kindconfigv1alpha4.Cluster{}
+	if err := sigsyaml.UnmarshalStrict(raw, cfg); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// ProviderConfigCheck checks that the crosslab configuration file exists,
+// is valid and does not use deprecated fields
+type ProviderConfigCheck struct {
+	path string
+}
+
+// NewProviderConfigCheck creates a check of a crosslab configuration file
+func NewProviderConfigCheck(path string) *ProviderConfigCheck {
+	return &ProviderConfigCheck{path: path}
+}
+
+// Name implements Check
+func (c *ProviderConfigCheck) Name() string {
+	return "Crosslab configuration"
+}
+
+// Run implements Check
+func (c *ProviderConfigCheck) Run(ctx context.Context) Result {
+	if !config.FileExists(c.path) {
+		return Fail("Run 'crosslab init' to create it", "%s not found", c.path)
+	}
+
+	cfg, err := config.LoadConfig(c.path)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		return Fail(fmt.Sprintf("Fix %s or recreate it with 'crosslab init'", c.path), "%v", err)
+	}
+
+	raw, err := os.ReadFile(c.path)
+	if err != nil {
+		return Fail("", "cannot read %s: %v", c.path, err)
+	}
+	var fields map[string]interface{}
+	if err := yaml.Unmarshal(raw, &fields); err == nil {
+		if _, ok := fields["aws"]; ok {
+			return Warn("Move the 'aws' block under 'families.aws'", "%s uses the deprecated top-level aws block", c.path)
+		}
+	}
+
+	return OK("%s declares %d package(s)", c.path, len(cfg.Packages()))
+}
@@ -0,0 +1,74 @@
+// Package doctor diagnoses the local environment crosslab runs in
+package doctor
+
+import (
+	"context"
+	"fmt"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	// StatusOK means the check passed
+	StatusOK Status = "ok"
+	// StatusWarn means the check found something that may cause problems
+	StatusWarn Status = "warn"
+	// StatusFail means the check found something that breaks crosslab
+	StatusFail Status = "fail"
+)
+
+// Result is the outcome of a check, with a hint on how to fix a problem
+type Result struct {
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// OK returns a passing result
+func OK(format string, args ...interface{}) Result {
+	return Result{Status: StatusOK, Message: fmt.Sprintf(format, args...)}
+}
+
+// Warn returns a warning result with a remediation hint
+func Warn(hint string, format string, args ...interface{}) Result {
+	return Result{Status: StatusWarn, Message: fmt.Sprintf(format, args...), Hint: hint}
+}
+
+// Fail returns a failing result with a remediation hint
+func Fail(hint string, format string, args ...interface{}) Result {
+	return Result{Status: StatusFail, Message: fmt.Sprintf(format, args...), Hint: hint}
+}
+
+// Check diagnoses one aspect of the environment
+type Check interface {
+	// Name is a short name of what is checked
+	Name() string
+	// Run runs the check
+	Run(ctx context.Context) Result
+}
+
+// Report is the result of a named check
+type Report struct {
+	Name string `json:"name"`
+	Result
+}
+
+// Run runs the checks in order and returns their reports
+func Run(ctx context.Context, checks []Check) []Report {
+	reports := make([]Report, 0, len(checks))
+	for _, check := range checks {
+		reports = append(reports, Report{Name: check.Name(), Result: check.Run(ctx)})
+	}
+	return reports
+}
+
+// Failed reports whether any check failed
+func Failed(reports []Report) bool {
+	for _, r := range reports {
+		if r.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/kanzifucius/crosslab/pkg/kube"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// staticCheck is a check with a fixed result
+type staticCheck struct {
+	name   string
+	result Result
+}
+
+func (c staticCheck) Name() string                   { return c.name }
+func (c staticCheck) Run(ctx context.Context) Result { return c.result }
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestRun(t *testing.T) {
+	reports := Run(context.Background(), []Check{
+		staticCheck{name: "a", result: OK("fine")},
+		staticCheck{name: "b", result: Warn("look", "hmm")},
+	})
+	assert.Equal(t, []Report{
+		{Name: "a", Result: Result{Status: StatusOK, Message: "fine"}},
+		{Name: "b", Result: Result{Status: StatusWarn, Message: "hmm", Hint: "look"}},
+	}, reports)
+	assert.False(t, Failed(reports))
+
+	reports = append(reports, Report{Name: "c", Result: Fail("fix", "broken")})
+	assert.True(t, Failed(reports))
+}
+
+func TestDockerCheck(t *testing.T) {
+	check := &DockerCheck{run: func(ctx context.Context, name string, args ...string) (string, error) {
+		return "27.3.1", nil
+	}}
+	assert.Equal(t, StatusOK, check.Run(context.Background()).Status)
+
+	check.run = func(ctx context.Context, name string, args ...string) (string, error) {
+		return "", fmt.Errorf("Cannot connect to the Docker daemon")
+	}
+	result := check.Run(context.Background())
+	assert.Equal(t, StatusFail, result.Status)
+	assert.NotEmpty(t, result.Hint)
+}
+
+func TestPortCheck(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	path := writeFile(t, t.TempDir(), "kind-config.yaml", fmt.Sprintf(`kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+nodes:
+- role: control-plane
+  extraPortMappings:
+  - containerPort: 80
+    hostPort: %d
+    listenAddress: 127.0.0.1
+`, port))
+
+	noCluster := func(ctx context.Context, name string, args ...string) (string, error) {
+		return "", nil
+	}
+	result := (&PortCheck{kindConfigPath: path, run: noCluster}).Run(context.Background())
+	assert.Equal(t, StatusFail, result.Status)
+	assert.Contains(t, result.Message, fmt.Sprint(port))
+
+	// A port published by a kind node is expected while the lab runs
+	kindNode := func(ctx context.Context, name string, args ...string) (string, error) {
+		return fmt.Sprintf("127.0.0.1:%d->80/tcp, 127.0.0.1:41234->6443/tcp", port), nil
+	}
+	result = (&PortCheck{kindConfigPath: path, run: kindNode}).Run(context.Background())
+	assert.Equal(t, StatusWarn, result.Status)
+	assert.Contains(t, result.Message, fmt.Sprint(port))
+
+	listener.Close()
+	assert.Equal(t, StatusOK, NewPortCheck(path).Run(context.Background()).Status)
+}
+
+func TestInotifyCheck(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("inotify limits only apply on linux")
+	}
+
+	dir := t.TempDir()
+	writeFile(t, dir, "max_user_watches", "8192\n")
+	writeFile(t, dir, "max_user_instances", "1024\n")
+
+	check := &InotifyCheck{procDir: dir}
+	result := check.Run(context.Background())
+	assert.Equal(t, StatusWarn, result.Status)
+	assert.Contains(t, result.Message, "max_user_watches=8192")
+	assert.NotContains(t, result.Message, "max_user_instances")
+
+	writeFile(t, dir, "max_user_watches", "524288\n")
+	assert.Equal(t, StatusOK, check.Run(context.Background()).Status)
+}
+
+func TestKubeconfigCheck(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config", `apiVersion: v1
+kind: Config
+current-context: kind-lab
+clusters:
+- name: kind-lab
+  cluster: {server: https://127.0.0.1:6443}
+contexts:
+- name: kind-lab
+  context: {cluster: kind-lab, user: kind-lab}
+users:
+- name: kind-lab
+  user: {token: secret}
+`)
+
+	assert.Equal(t, StatusOK, NewKubeconfigCheck(kube.NewClientFactory(path, "", "")).Run(context.Background()).Status)
+	assert.Equal(t, StatusFail, NewKubeconfigCheck(kube.NewClientFactory(path, "kind-other", "")).Run(context.Background()).Status)
+
+	empty := writeFile(t, dir, "empty", "apiVersion: v1\nkind: Config\n")
+	assert.Equal(t, StatusWarn, NewKubeconfigCheck(kube.NewClientFactory(empty, "", "")).Run(context.Background()).Status)
+}
+
+func TestConfigChecks(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	t.Run("missing", func(t *testing.T) {
+		result := NewKindConfigCheck(filepath.Join(dir, "missing.yaml")).Run(ctx)
+		assert.Equal(t, StatusFail, result.Status)
+		assert.Contains(t, result.Hint, "crosslab init")
+
+		assert.Equal(t, StatusFail, NewProviderConfigCheck(filepath.Join(dir, "missing.yaml")).Run(ctx).Status)
+	})
+
+	t.Run("kind config", func(t *testing.T) {
+		path := writeFile(t, dir, "kind-config.yaml", "kind: Cluster\napiVersion: kind.x-k8s.io/v1alpha4\nnodes:\n- role: control-plane\n")
+		assert.Equal(t, StatusOK, NewKindConfigCheck(path).Run(ctx).Status)
+
+		path = writeFile(t, dir, "kind-unknown.yaml", "kind: Cluster\napiVersion: kind.x-k8s.io/v1alpha4\nworkers: 2\n")
+		assert.Equal(t, StatusFail, NewKindConfigCheck(path).Run(ctx).Status)
+	})
+
+	t.Run("provider config", func(t *testing.T) {
+		path := writeFile(t, dir, "crosslab-config.yaml", "otherProviders:\n- {name: provider-helm, package: xpkg.upbound.io/upbound/provider-helm, version: v0.20.4}\n")
+		assert.Equal(t, StatusOK, NewProviderConfigCheck(path).Run(ctx).Status)
+
+		path = writeFile(t, dir, "legacy.yaml", "aws:\n  family: {name: upbound-provider-aws, package: xpkg.upbound.io/upbound/provider-family-aws, version: v1}\n")
+		result := NewProviderConfigCheck(path).Run(ctx)
+		assert.Equal(t, StatusWarn, result.Status)
+		assert.Contains(t, result.Hint, "families.aws")
+
+		path = writeFile(t, dir, "incomplete.yaml", "otherProviders:\n- {name: provider-helm}\n")
+		assert.Equal(t, StatusFail, NewProviderConfigCheck(path).Run(ctx).Status)
+	})
+}
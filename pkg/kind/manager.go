@@ -2,21 +2,66 @@ package kind
 
 import (
 	"fmt"
+	"os"
+	"time"
 
 	"sigs.k8s.io/kind/pkg/cluster"
+	"sigs.k8s.io/kind/pkg/cluster/nodes"
+	"sigs.k8s.io/kind/pkg/cluster/nodeutils"
 	"sigs.k8s.io/kind/pkg/cmd"
+	"sigs.k8s.io/kind/pkg/log"
 )
 
 // Manager defines the operations that can be performed on a Kind cluster
 type Manager interface {
 	// ClusterExists checks if a cluster with the given name exists
 	ClusterExists(name string) (bool, error)
-	// CreateCluster creates a new Kind cluster using the provided configuration file
-	CreateCluster(configFilePath string, name string) error
+	// CreateCluster creates a new Kind cluster using the provided configuration
+	// file and returns its kubeconfig
+	CreateCluster(configFilePath string, name string, opts CreateOptions) (string, error)
 	// DeleteCluster deletes a Kind cluster by name
 	DeleteCluster(name string) error
 	// ListClusters returns a list of existing Kind clusters
 	ListClusters() ([]string, error)
+	// LoadImageArchives loads image archives into every node of a cluster
+	LoadImageArchives(name string, archives []string) error
+	// NodeStatuses returns the state of every node container of a cluster
+	NodeStatuses(name string) ([]NodeStatus, error)
+	// KubeConfig returns the kubeconfig of a cluster
+	KubeConfig(name string, internal bool) (string, error)
+	// ExportKubeConfig merges the kubeconfig of a cluster into a kubeconfig
+	// file, the default kubeconfig if path is empty
+	ExportKubeConfig(name string, path string, internal bool) error
+}
+
+// ContextName returns the kubeconfig context name of a Kind cluster
+func ContextName(name string) string {
+	return "kind-" + name
+}
+
+// CreateOptions configures the creation of a cluster
+type CreateOptions struct {
+	// LocalRegistry starts a local registry container reachable at
+	// RegistryHost and wires it into the cluster
+	LocalRegistry bool
+	// NodeImage is the image of every node, overriding the images of the
+	// cluster configuration; empty keeps them
+	NodeImage string
+	// Wait is how long to wait for the control plane to become ready; zero
+	// does not wait
+	Wait time.Duration
+	// Retain keeps the nodes when creation fails, so they can be debugged
+	Retain bool
+	// StopBeforeSettingUpKubernetes only creates the node containers, without
+	// running kubeadm; no kubeconfig is returned
+	StopBeforeSettingUpKubernetes bool
+	// DisplayUsage prints how to use the cluster once it is created
+	DisplayUsage bool
+	// DisplaySalutation prints a salutation once the cluster is created
+	DisplaySalutation bool
+	// KubeconfigPath is the kubeconfig file the cluster is merged into;
+	// empty uses $KUBECONFIG or ~/.kube/config
+	KubeconfigPath string
 }
 
 // manager handles Kind cluster operations
@@ -24,15 +69,30 @@ type manager struct {
 	provider cluster.Provider
 }
 
-// NewManager creates a new Kind cluster manager
+// NewManager creates a new Kind cluster manager logging like the kind CLI
 func NewManager() Manager {
+	return NewManagerWithLogger(cmd.NewLogger())
+}
+
+// NewManagerWithLogger creates a new Kind cluster manager with a custom logger
+func NewManagerWithLogger(logger log.Logger) Manager {
 	return &manager{
 		provider: *cluster.NewProvider(
-			cluster.ProviderWithLogger(cmd.NewLogger()),
+			cluster.ProviderWithLogger(logger),
 		),
 	}
 }
 
+// NewLogger returns the kind CLI logger at the given verbosity; higher levels
+// print more of what kind is doing
+func NewLogger(verbosity int) log.Logger {
+	logger := cmd.NewLogger()
+	if l, ok := logger.(interface{ SetVerbosity(log.Level) }); ok {
+		l.SetVerbosity(log.Level(verbosity))
+	}
+	return logger
+}
+
 // ClusterExists checks if a cluster with the given name exists
 func (m *manager) ClusterExists(name string) (bool, error) {
 	clusters, err := m.provider.List()
@@ -49,26 +109,65 @@ func (m *manager) ClusterExists(name string) (bool, error) {
 	return false, nil
 }
 
-// CreateCluster creates a new Kind cluster using the provided configuration file
-func (m *manager) CreateCluster(configFilePath string, name string) error {
+// CreateCluster creates a new Kind cluster using the provided configuration
+// file and returns its kubeconfig. Kind also merges the kubeconfig into
+// opts.KubeconfigPath, or the default kubeconfig, as context kind-<name>.
+func (m *manager) CreateCluster(configFilePath string, name string, opts CreateOptions) (string, error) {
+	if opts.LocalRegistry && opts.StopBeforeSettingUpKubernetes {
+		return "", fmt.Errorf("the local registry cannot be wired into a cluster that stops before setting up Kubernetes")
+	}
+
 	exists, err := m.ClusterExists(name)
 	if err != nil {
-		return fmt.Errorf("error checking cluster existence: %v", err)
+		return "", fmt.Errorf("error checking cluster existence: %v", err)
 	}
 
 	if exists {
-		return fmt.Errorf("cluster %s already exists", name)
+		return "", fmt.Errorf("cluster %s already exists", name)
 	}
 
+	createOpts := []cluster.CreateOption{cluster.CreateWithConfigFile(configFilePath)}
+	if opts.LocalRegistry {
+		if err := ensureRegistry(); err != nil {
+			return "", err
+		}
+
+		cfg, err := loadClusterConfig(configFilePath)
+		if err != nil {
+			return "", err
+		}
+		createOpts = []cluster.CreateOption{cluster.CreateWithV1Alpha4Config(cfg)}
+	}
+
+	if opts.NodeImage != "" {
+		createOpts = append(createOpts, cluster.CreateWithNodeImage(opts.NodeImage))
+	}
+
+	createOpts = append(createOpts,
+		cluster.CreateWithWaitForReady(opts.Wait),
+		cluster.CreateWithRetain(opts.Retain),
+		cluster.CreateWithStopBeforeSettingUpKubernetes(opts.StopBeforeSettingUpKubernetes),
+		cluster.CreateWithDisplayUsage(opts.DisplayUsage),
+		cluster.CreateWithDisplaySalutation(opts.DisplaySalutation),
+		cluster.CreateWithKubeconfigPath(opts.KubeconfigPath),
+	)
+
 	// Create the cluster
-	if err := m.provider.Create(
-		name,
-		cluster.CreateWithConfigFile(configFilePath),
-	); err != nil {
-		return fmt.Errorf("error creating cluster: %v", err)
+	if err := m.provider.Create(name, createOpts...); err != nil {
+		return "", fmt.Errorf("error creating cluster: %v", err)
 	}
 
-	return nil
+	if opts.StopBeforeSettingUpKubernetes {
+		return "", nil
+	}
+
+	if opts.LocalRegistry {
+		if err := m.connectRegistry(name); err != nil {
+			return "", err
+		}
+	}
+
+	return m.KubeConfig(name, false)
 }
 
 // DeleteCluster deletes a Kind cluster by name
@@ -89,3 +188,57 @@ func (m *manager) ListClusters() ([]string, error) {
 
 	return clusters, nil
 }
+
+// LoadImageArchives loads image archives, as written by docker save, into the
+// containerd image store of every node of a cluster, like kind load image-archive
+func (m *manager) LoadImageArchives(name string, archives []string) error {
+	clusterNodes, err := m.provider.ListInternalNodes(name)
+	if err != nil {
+		return fmt.Errorf("error listing cluster nodes: %v", err)
+	}
+	if len(clusterNodes) == 0 {
+		return fmt.Errorf("no nodes found for cluster %s", name)
+	}
+
+	for _, archive := range archives {
+		for _, node := range clusterNodes {
+			if err := loadImageArchive(node, archive); err != nil {
+				return fmt.Errorf("error loading %s into node %s: %v", archive, node.String(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadImageArchive loads a single image archive into a node
+func loadImageArchive(node nodes.Node, archive string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return nodeutils.LoadImageArchive(node, f)
+}
+
+// KubeConfig returns the kubeconfig of a cluster. The internal kubeconfig
+// uses the API server address within the kind network.
+func (m *manager) KubeConfig(name string, internal bool) (string, error) {
+	kubeconfig, err := m.provider.KubeConfig(name, internal)
+	if err != nil {
+		return "", fmt.Errorf("error getting kubeconfig: %v", err)
+	}
+
+	return kubeconfig, nil
+}
+
+// ExportKubeConfig merges the kubeconfig of a cluster into a kubeconfig file,
+// the default kubeconfig if path is empty, and makes it the current context
+func (m *manager) ExportKubeConfig(name string, path string, internal bool) error {
+	if err := m.provider.ExportKubeConfig(name, path, internal); err != nil {
+		return fmt.Errorf("error exporting kubeconfig: %v", err)
+	}
+
+	return nil
+}
@@ -99,11 +99,11 @@ func TestClusterOperations(t *testing.T) {
 				return false, nil
 			}
 
-			mockManager.CreateClusterFunc = func(configFile, name string) error {
+			mockManager.CreateClusterFunc = func(configFile, name string, opts CreateOptions) (string, error) {
 				if tt.wantCreateErr {
-					return fmt.Errorf("mock create error")
+					return "", fmt.Errorf("mock create error")
 				}
-				return nil
+				return "apiVersion: v1\nkind: Config\n", nil
 			}
 
 			mockManager.DeleteClusterFunc = func(name string) error {
@@ -124,10 +124,13 @@ func TestClusterOperations(t *testing.T) {
 			}
 
 			// Test CreateCluster
-			err = mockManager.CreateCluster(tt.configFile, tt.clusterName)
+			kubeconfig, err := mockManager.CreateCluster(tt.configFile, tt.clusterName, CreateOptions{})
 			if (err != nil) != tt.wantCreateErr {
 				t.Errorf("CreateCluster() error = %v, wantCreateErr %v", err, tt.wantCreateErr)
 			}
+			if err == nil && kubeconfig == "" {
+				t.Errorf("CreateCluster() returned an empty kubeconfig")
+			}
 
 			// Test DeleteCluster
 			err = mockManager.DeleteCluster(tt.clusterName)
@@ -137,3 +140,13 @@ func TestClusterOperations(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateClusterOptions(t *testing.T) {
+	_, err := NewManagerWithLogger(NewLogger(0)).CreateCluster("kind-config.yaml", "test-cluster", CreateOptions{
+		LocalRegistry:                 true,
+		StopBeforeSettingUpKubernetes: true,
+	})
+	if err == nil {
+		t.Errorf("CreateCluster() expected an error for a local registry without Kubernetes")
+	}
+}
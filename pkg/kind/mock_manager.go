@@ -3,9 +3,13 @@ package kind
 // mockManager implements Manager interface for testing
 type mockManager struct {
 	ClusterExistsFunc func(name string) (bool, error)
-	CreateClusterFunc func(configFilePath string, name string) error
+	CreateClusterFunc func(configFilePath string, name string, opts CreateOptions) (string, error)
 	DeleteClusterFunc func(name string) error
 	ListClustersFunc  func() ([]string, error)
+	LoadImagesFunc    func(name string, archives []string) error
+	NodeStatusesFunc  func(name string) ([]NodeStatus, error)
+	KubeConfigFunc    func(name string, internal bool) (string, error)
+	ExportFunc        func(name string, path string, internal bool) error
 }
 
 // NewMockManager creates a new mock kind cluster manager
@@ -20,11 +24,11 @@ func (m *mockManager) ClusterExists(name string) (bool, error) {
 	return false, nil
 }
 
-func (m *mockManager) CreateCluster(configFilePath string, name string) error {
+func (m *mockManager) CreateCluster(configFilePath string, name string, opts CreateOptions) (string, error) {
 	if m.CreateClusterFunc != nil {
-		return m.CreateClusterFunc(configFilePath, name)
+		return m.CreateClusterFunc(configFilePath, name, opts)
 	}
-	return nil
+	return "", nil
 }
 
 func (m *mockManager) DeleteCluster(name string) error {
@@ -40,3 +44,31 @@ func (m *mockManager) ListClusters() ([]string, error) {
 	}
 	return nil, nil
 }
+
+func (m *mockManager) LoadImageArchives(name string, archives []string) error {
+	if m.LoadImagesFunc != nil {
+		return m.LoadImagesFunc(name, archives)
+	}
+	return nil
+}
+
+func (m *mockManager) NodeStatuses(name string) ([]NodeStatus, error) {
+	if m.NodeStatusesFunc != nil {
+		return m.NodeStatusesFunc(name)
+	}
+	return nil, nil
+}
+
+func (m *mockManager) KubeConfig(name string, internal bool) (string, error) {
+	if m.KubeConfigFunc != nil {
+		return m.KubeConfigFunc(name, internal)
+	}
+	return "", nil
+}
+
+func (m *mockManager) ExportKubeConfig(name string, path string, internal bool) error {
+	if m.ExportFunc != nil {
+		return m.ExportFunc(name, path, internal)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package kind
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"sigs.k8s.io/kind/pkg/cmd/kind/version"
+)
+
+// NodeImageRepo is the repository of the kind node images
+const NodeImageRepo = "kindest/node"
+
+// nodeImages lists the node images published with each kind release, by
+// Kubernetes minor version, as given in the kind release notes. Add an entry
+// when upgrading sigs.k8s.io/kind, as node images are built for a release.
+var nodeImages = map[string]map[string]string{
+	"0.27.0": {
+		"1.32": NodeImageRepo + ":v1.32.2@sha256:f226345927d7e348497136874b6d207e0b32cc52154ad8323129352923a3142f",
+		"1.31": NodeImageRepo + ":v1.31.6@sha256:28b7cbb993dfe093c76641a0c95807637213c9109b761f1d422c2400e22b8e87",
+		"1.30": NodeImageRepo + ":v1.30.10@sha256:4de75d0e82481ea846c0ed1de86328d821c1e6a6a91ac37bf804e5313670e507",
+		"1.29": NodeImageRepo + ":v1.29.14@sha256:8703bd94ee24e51b778d5556ae310c6c0fa67d761fae6379c8e0bb480e6fea29",
+	},
+}
+
+// Version returns the version of kind clusters are created with
+func Version() string {
+	return version.Version()
+}
+
+// KubernetesVersions returns the Kubernetes minor versions with a known node
+// image for the kind version in use, newest first
+func KubernetesVersions() []string {
+	return kubernetesVersions(nodeImages[Version()])
+}
+
+// kubernetesVersions returns the minor versions of a node image table, newest first
+func kubernetesVersions(images map[string]string) []string {
+	versions := make([]*semver.Version, 0, len(images))
+	for minor := range images {
+		if v, err := semver.NewVersion(minor); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	sort.Sort(sort.Reverse(semver.Collection(versions)))
+
+	minors := make([]string, 0, len(versions))
+	for _, v := range versions {
+		minors = append(minors, fmt.Sprintf("%d.%d", v.Major(), v.Minor()))
+	}
+	return minors
+}
+
+// NodeImage returns the node image for a Kubernetes version such as 1.31,
+// v1.31 or 1.31.6. A minor version resolves to the image pinned by digest for
+// the kind version in use; a patch version that is not the pinned one
+// resolves to its tag, which must have been published for this kind version.
+func NodeImage(kubernetesVersion string) (string, error) {
+	return nodeImage(nodeImages[Version()], kubernetesVersion)
+}
+
+// nodeImage looks a Kubernetes version up in a node image table
+func nodeImage(images map[string]string, kubernetesVersion string) (string, error) {
+	v, err := semver.NewVersion(kubernetesVersion)
+	if err != nil {
+		return "", fmt.Errorf("invalid Kubernetes version %q: %v", kubernetesVersion, err)
+	}
+
+	image, ok := images[fmt.Sprintf("%d.%d", v.Major(), v.Minor())]
+	if !ok {
+		return "", fmt.Errorf("no node image known for Kubernetes %s with kind %s, supported versions: %s",
+			kubernetesVersion, Version(), strings.Join(kubernetesVersions(images), ", "))
+	}
+
+	// A minor version, or the patch version of the pinned image
+	tag := "v" + v.String()
+	if strings.Count(strings.TrimPrefix(kubernetesVersion, "v"), ".") < 2 || strings.HasPrefix(image, NodeImageRepo+":"+tag+"@") {
+		return image, nil
+	}
+
+	return NodeImageRepo + ":" + tag, nil
+}
+
+// ResolveNodeImage returns the node image for an explicit image or a
+// Kubernetes version, of which at most one may be set. It returns an empty
+// image when neither is set, so the images of the cluster configuration or
+// the kind default are used.
+func ResolveNodeImage(image string, kubernetesVersion string) (string, error) {
+	if image != "" && kubernetesVersion != "" {
+		return "", fmt.Errorf("set either a node image or a Kubernetes version, not both")
+	}
+
+	if kubernetesVersion != "" {
+		return NodeImage(kubernetesVersion)
+	}
+
+	return image, nil
+}
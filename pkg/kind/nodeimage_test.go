@@ -0,0 +1,58 @@
+package kind
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeImage(t *testing.T) {
+	images := map[string]string{
+		"1.30": NodeImageRepo + ":v1.30.10@sha256:30",
+		"1.31": NodeImageRepo + ":v1.31.6@sha256:31",
+		"1.29": NodeImageRepo + ":v1.29.14@sha256:29",
+	}
+
+	tests := []struct {
+		name    string
+		version string
+		want    string
+		wantErr string
+	}{
+		{name: "minor", version: "1.31", want: NodeImageRepo + ":v1.31.6@sha256:31"},
+		{name: "minor with prefix", version: "v1.30", want: NodeImageRepo + ":v1.30.10@sha256:30"},
+		{name: "pinned patch", version: "1.29.14", want: NodeImageRepo + ":v1.29.14@sha256:29"},
+		{name: "other patch", version: "v1.31.2", want: NodeImageRepo + ":v1.31.2"},
+		{name: "unknown minor", version: "1.25", wantErr: "supported versions: 1.31, 1.30, 1.29"},
+		{name: "invalid", version: "latest", wantErr: "invalid Kubernetes version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nodeImage(images, tt.version)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("kind version in use", func(t *testing.T) {
+		assert.NotEmpty(t, KubernetesVersions(), "no node images known for kind %s", Version())
+	})
+}
+
+func TestResolveNodeImage(t *testing.T) {
+	image, err := ResolveNodeImage("", "")
+	assert.NoError(t, err)
+	assert.Empty(t, image)
+
+	image, err = ResolveNodeImage("kindest/node:v1.31.6", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "kindest/node:v1.31.6", image)
+
+	_, err = ResolveNodeImage("kindest/node:v1.31.6", "1.31")
+	assert.ErrorContains(t, err, "not both")
+}
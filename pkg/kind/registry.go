@@ -0,0 +1,238 @@
+package kind
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+	"sigs.k8s.io/kind/pkg/cluster/nodes"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// RegistryName is the name of the local registry container
+	RegistryName = "kind-registry"
+	// RegistryImage is the image the local registry runs
+	RegistryImage = "registry:2"
+	// RegistryHost is the address the local registry is reachable at from the
+	// host and, through the containerd mirror, from the cluster nodes
+	RegistryHost = "localhost:5001"
+	// RegistryClusterHost is the address the local registry is reachable at
+	// from pods, such as Crossplane's package manager. It names a Service in
+	// front of the registry container; the .local suffix makes registry
+	// clients use plain http.
+	RegistryClusterHost = RegistryName + "." + registryNamespace + ".svc.cluster.local:" + registryInternalPort
+
+	// registryInternalPort is the port the registry listens on in the kind network
+	registryInternalPort = "5000"
+	// registryNamespace holds the Service and the ConfigMap of the local registry
+	registryNamespace = "kube-public"
+	// kindNetwork is the docker network kind nodes are attached to
+	kindNetwork = "kind"
+	// containerdCertsDir is the containerd registry host configuration directory
+	containerdCertsDir = "/etc/containerd/certs.d"
+)
+
+// registryConfigPatch makes containerd read registry hosts from containerdCertsDir
+var registryConfigPatch = fmt.Sprintf(`[plugins."io.containerd.grpc.v1.cri".registry]
+  config_path = %q
+`, containerdCertsDir)
+
+// ensureRegistry starts the local registry container, creating it if needed
+func ensureRegistry() error {
+	running, err := docker("inspect", "-f", "{{.State.Running}}", RegistryName)
+	if err != nil {
+		fmt.Printf("Starting local registry %s on %s...\n", RegistryName, RegistryHost)
+		_, err := docker("run", "-d", "--restart=always",
+			"-p", "127.0.0.1:"+strings.TrimPrefix(RegistryHost, "localhost:")+":"+registryInternalPort,
+			"--network", "bridge", "--name", RegistryName, RegistryImage)
+		if err != nil {
+			return fmt.Errorf("error starting local registry: %v", err)
+		}
+		return nil
+	}
+
+	if running != "true" {
+		if _, err := docker("start", RegistryName); err != nil {
+			return fmt.Errorf("error starting local registry: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// loadClusterConfig reads a kind cluster configuration file and adds the
+// containerd patch needed by the local registry
+func loadClusterConfig(configFilePath string) (*v1alpha4.Cluster, error) {
+	raw, err := os.ReadFile(configFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cluster config: %v", err)
+	}
+
+	cfg := &v1alpha4.Cluster{}
+	if err := yaml.UnmarshalStrict(raw, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing cluster config: %v", err)
+	}
+
+	cfg.ContainerdConfigPatches = append(cfg.ContainerdConfigPatches, registryConfigPatch)
+	return cfg, nil
+}
+
+// connectRegistry wires the local registry into a created cluster: every
+// node mirrors RegistryHost and RegistryClusterHost to the registry
+// container, the container joins the kind network, and the Service behind
+// RegistryClusterHost and the local-registry-hosting ConfigMap are published
+func (m *manager) connectRegistry(name string) error {
+	clusterNodes, err := m.provider.ListInternalNodes(name)
+	if err != nil {
+		return fmt.Errorf("error listing cluster nodes: %v", err)
+	}
+
+	hosts := fmt.Sprintf("[host.\"http://%s:%s\"]\n", RegistryName, registryInternalPort)
+	for _, node := range clusterNodes {
+		for _, host := range []string{RegistryHost, RegistryClusterHost} {
+			hostsDir := path.Join(containerdCertsDir, host)
+			if err := node.Command("mkdir", "-p", hostsDir).Run(); err != nil {
+				return fmt.Errorf("error configuring registry on node %s: %v", node.String(), err)
+			}
+			if err := writeNodeFile(node, path.Join(hostsDir, "hosts.toml"), hosts); err != nil {
+				return fmt.Errorf("error configuring registry on node %s: %v", node.String(), err)
+			}
+		}
+	}
+
+	networks, err := docker("inspect", "-f", "{{json .NetworkSettings.Networks}}", RegistryName)
+	if err != nil {
+		return fmt.Errorf("error inspecting local registry: %v", err)
+	}
+	if !strings.Contains(networks, fmt.Sprintf("%q", kindNetwork)) {
+		if _, err := docker("network", "connect", kindNetwork, RegistryName); err != nil {
+			return fmt.Errorf("error connecting local registry to the kind network: %v", err)
+		}
+	}
+
+	address, err := docker("inspect", "-f", fmt.Sprintf("{{(index .NetworkSettings.Networks %q).IPAddress}}", kindNetwork), RegistryName)
+	if err != nil {
+		return fmt.Errorf("error inspecting local registry: %v", err)
+	}
+
+	return m.publishRegistryHosting(name, address)
+}
+
+// publishRegistryHosting creates the Service that routes RegistryClusterHost
+// to the registry container at address, and the ConfigMap that documents the
+// local registry to tools running against the cluster (KEP-1755)
+func (m *manager) publishRegistryHosting(name string, address string) error {
+	kubeconfig, err := m.provider.KubeConfig(name, false)
+	if err != nil {
+		return fmt.Errorf("error getting cluster kubeconfig: %v", err)
+	}
+
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
+	if err != nil {
+		return fmt.Errorf("error loading cluster kubeconfig: %v", err)
+	}
+
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client: %v", err)
+	}
+
+	ctx := context.Background()
+	service, endpoints := registryServiceObjects(address)
+	_, err = client.CoreV1().Services(service.Namespace).Create(ctx, service, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("error creating local registry Service: %v", err)
+	}
+	_, err = client.CoreV1().Endpoints(endpoints.Namespace).Create(ctx, endpoints, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		// The registry container may have a new address after a restart
+		_, err = client.CoreV1().Endpoints(endpoints.Namespace).Update(ctx, endpoints, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("error publishing local registry endpoints: %v", err)
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "local-registry-hosting",
+			Namespace: registryNamespace,
+		},
+		Data: map[string]string{
+			"localRegistryHosting.v1": fmt.Sprintf("host: %q\nhostFromClusterNetwork: %q\nhelp: \"https://kind.sigs.k8s.io/docs/user/local-registry/\"\n", RegistryHost, RegistryClusterHost),
+		},
+	}
+
+	_, err = client.CoreV1().ConfigMaps(cm.Namespace).Create(ctx, cm, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		_, err = client.CoreV1().ConfigMaps(cm.Namespace).Update(ctx, cm, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("error publishing local registry ConfigMap: %v", err)
+	}
+
+	return nil
+}
+
+// registryServiceObjects builds the selectorless Service behind
+// RegistryClusterHost and the Endpoints pointing it at the registry container
+func registryServiceObjects(address string) (*corev1.Service, *corev1.Endpoints) {
+	port, _ := strconv.Atoi(registryInternalPort)
+	meta := metav1.ObjectMeta{Name: RegistryName, Namespace: registryNamespace}
+
+	service := &corev1.Service{
+		ObjectMeta: meta,
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{Name: "registry", Port: int32(port)}},
+		},
+	}
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: meta,
+		Subsets: []corev1.EndpointSubset{{
+			Addresses: []corev1.EndpointAddress{{IP: address}},
+			Ports:     []corev1.EndpointPort{{Name: "registry", Port: int32(port)}},
+		}},
+	}
+	return service, endpoints
+}
+
+// ClusterRef returns a package reference on the local registry as pods
+// reach it; other references are returned unchanged
+func ClusterRef(ref string) string {
+	if strings.HasPrefix(ref, RegistryHost+"/") {
+		return RegistryClusterHost + strings.TrimPrefix(ref, RegistryHost)
+	}
+	return ref
+}
+
+// HostRef reverses ClusterRef
+func HostRef(ref string) string {
+	if strings.HasPrefix(ref, RegistryClusterHost+"/") {
+		return RegistryHost + strings.TrimPrefix(ref, RegistryClusterHost)
+	}
+	return ref
+}
+
+// writeNodeFile writes content to a file on a node
+func writeNodeFile(node nodes.Node, file string, content string) error {
+	return node.Command("cp", "/dev/stdin", file).SetStdin(strings.NewReader(content)).Run()
+}
+
+// docker runs a docker command and returns its trimmed output
+func docker(args ...string) (string, error) {
+	out, err := exec.Command("docker", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("docker %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
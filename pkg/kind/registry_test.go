@@ -0,0 +1,35 @@
+package kind
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadClusterConfig(t *testing.T) {
+	cfg, err := loadClusterConfig("../../examples/config/kind-config.yaml")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, cfg.Nodes)
+	assert.Contains(t, cfg.ContainerdConfigPatches, registryConfigPatch)
+
+	_, err = loadClusterConfig("missing.yaml")
+	assert.Error(t, err)
+}
+
+func TestClusterRef(t *testing.T) {
+	ref := ClusterRef("localhost:5001/platform-ref@sha256:0a1b2c3d")
+	assert.Equal(t, "kind-registry.kube-public.svc.cluster.local:5000/platform-ref@sha256:0a1b2c3d", ref)
+	assert.Equal(t, "localhost:5001/platform-ref@sha256:0a1b2c3d", HostRef(ref))
+
+	assert.Equal(t, "xpkg.upbound.io/upbound/provider-helm:v0.20.4", ClusterRef("xpkg.upbound.io/upbound/provider-helm:v0.20.4"))
+	assert.Equal(t, "localhost:5000/platform-ref:v0.1.0", ClusterRef("localhost:5000/platform-ref:v0.1.0"))
+}
+
+func TestRegistryServiceObjects(t *testing.T) {
+	service, endpoints := registryServiceObjects("172.18.0.3")
+	assert.Equal(t, RegistryName, service.Name)
+	assert.Empty(t, service.Spec.Selector)
+	assert.Equal(t, int32(5000), service.Spec.Ports[0].Port)
+	assert.Equal(t, "172.18.0.3", endpoints.Subsets[0].Addresses[0].IP)
+	assert.Equal(t, service.Spec.Ports[0].Name, endpoints.Subsets[0].Ports[0].Name)
+}
@@ -0,0 +1,45 @@
+package kind
+
+import (
+	"fmt"
+)
+
+// NodeStatus is the state of a node container of a cluster
+type NodeStatus struct {
+	Name string `json:"name" yaml:"name"`
+	Role string `json:"role" yaml:"role"`
+	// State is the container state, e.g. running or exited
+	State string `json:"state" yaml:"state"`
+}
+
+// Running reports whether the node container is running
+func (s NodeStatus) Running() bool {
+	return s.State == "running"
+}
+
+// NodeStatuses returns the state of every node container of a cluster
+func (m *manager) NodeStatuses(name string) ([]NodeStatus, error) {
+	clusterNodes, err := m.provider.ListNodes(name)
+	if err != nil {
+		return nil, fmt.Errorf("error listing cluster nodes: %v", err)
+	}
+
+	statuses := make([]NodeStatus, 0, len(clusterNodes))
+	for _, node := range clusterNodes {
+		status := NodeStatus{Name: node.String()}
+
+		status.Role, err = node.Role()
+		if err != nil {
+			return nil, fmt.Errorf("error getting role of node %s: %v", node.String(), err)
+		}
+
+		status.State, err = docker("inspect", "-f", "{{.State.Status}}", node.String())
+		if err != nil {
+			return nil, fmt.Errorf("error inspecting node %s: %v", node.String(), err)
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
@@ -0,0 +1,79 @@
+// Package kube builds Kubernetes clients that share a single kubeconfig context
+package kube
+
+import (
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/cli"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ClientFactory resolves the cluster to talk to from a kubeconfig path, a
+// context and a namespace, so that the Kubernetes clients and Helm always
+// target the same cluster
+type ClientFactory struct {
+	// Kubeconfig is the path of the kubeconfig file; empty uses $KUBECONFIG
+	// or ~/.kube/config
+	Kubeconfig string
+	// Context is the kubeconfig context; empty uses the current context
+	Context string
+	// Namespace is the namespace Crossplane is installed into
+	Namespace string
+}
+
+// NewClientFactory creates a client factory for a kubeconfig context
+func NewClientFactory(kubeconfig string, context string, namespace string) *ClientFactory {
+	return &ClientFactory{
+		Kubeconfig: kubeconfig,
+		Context:    context,
+		Namespace:  namespace,
+	}
+}
+
+// clientConfig returns the kubeconfig loader of the factory's context
+func (f *ClientFactory) clientConfig() clientcmd.ClientConfig {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = f.Kubeconfig
+	configOverrides := &clientcmd.ConfigOverrides{CurrentContext: f.Context}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
+}
+
+// RESTConfig returns the REST config of the factory's context
+func (f *ClientFactory) RESTConfig() (*rest.Config, error) {
+	config, err := f.clientConfig().ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Kubernetes config: %v", err)
+	}
+
+	return config, nil
+}
+
+// RawConfig returns the merged kubeconfig, with the current context set to
+// the factory's context
+func (f *ClientFactory) RawConfig() (clientcmdapi.Config, error) {
+	config, err := f.clientConfig().RawConfig()
+	if err != nil {
+		return clientcmdapi.Config{}, fmt.Errorf("failed to load kubeconfig: %v", err)
+	}
+
+	if f.Context != "" {
+		config.CurrentContext = f.Context
+	}
+	return config, nil
+}
+
+// HelmSettings returns Helm settings for the factory's context and namespace
+func (f *ClientFactory) HelmSettings() *cli.EnvSettings {
+	settings := cli.New()
+	if f.Kubeconfig != "" {
+		settings.KubeConfig = f.Kubeconfig
+	}
+	if f.Context != "" {
+		settings.KubeContext = f.Context
+	}
+	settings.SetNamespace(f.Namespace)
+
+	return settings
+}
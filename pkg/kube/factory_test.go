@@ -0,0 +1,67 @@
+package kube
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com
+- name: lab
+  cluster:
+    server: https://lab.example.com
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: admin
+- name: kind-lab
+  context:
+    cluster: lab
+    user: admin
+users:
+- name: admin
+  user:
+    token: secret
+`
+
+func TestClientFactory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	assert.NoError(t, os.WriteFile(path, []byte(testKubeconfig), 0600))
+
+	t.Run("current context", func(t *testing.T) {
+		config, err := NewClientFactory(path, "", "crossplane-system").RESTConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, "https://dev.example.com", config.Host)
+	})
+
+	t.Run("explicit context", func(t *testing.T) {
+		factory := NewClientFactory(path, "kind-lab", "crossplane")
+
+		config, err := factory.RESTConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, "https://lab.example.com", config.Host)
+
+		settings := factory.HelmSettings()
+		assert.Equal(t, path, settings.KubeConfig)
+		assert.Equal(t, "kind-lab", settings.KubeContext)
+		assert.Equal(t, "crossplane", settings.Namespace())
+
+		helmConfig, err := settings.RESTClientGetter().ToRESTConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, config.Host, helmConfig.Host)
+	})
+
+	t.Run("unknown context", func(t *testing.T) {
+		_, err := NewClientFactory(path, "missing", "crossplane-system").RESTConfig()
+		assert.ErrorContains(t, err, "failed to get Kubernetes config")
+	})
+}
@@ -0,0 +1,451 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/kanzifucius/crosslab/pkg/cache"
+	"github.com/kanzifucius/crosslab/pkg/config"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"helm.sh/helm/v3/pkg/strvals"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// CrossplaneHelmTimeout is the time Helm waits for the Crossplane release to become ready
+const CrossplaneHelmTimeout = 5 * time.Minute
+
+// UninstallOptions configures the removal of Crossplane
+type UninstallOptions struct {
+	// RemovePackages deletes every package before Crossplane is uninstalled,
+	// and the Crossplane CRDs afterwards
+	RemovePackages bool
+}
+
+// InstallCrossplane installs the Crossplane Helm chart, or upgrades the
+// release if Crossplane is already installed
+func (m *manager) InstallCrossplane(ctx context.Context, cfg config.CrossplaneConfig) error {
+	if err := m.ensureNamespace(ctx); err != nil {
+		return err
+	}
+
+	settings, actionConfig, err := m.helmConfig()
+	if err != nil {
+		return err
+	}
+
+	exists, err := releaseExists(actionConfig)
+	if err != nil {
+		return err
+	}
+	if exists {
+		fmt.Println("Crossplane is already installed, upgrading the release...")
+		return upgradeCrossplane(settings, actionConfig, cfg)
+	}
+
+	// Create Helm install client
+	client := action.NewInstall(actionConfig)
+	client.Namespace = m.namespace
+	client.CreateNamespace = true
+	client.Wait = true
+	client.Timeout = CrossplaneHelmTimeout
+	client.ReleaseName = CrossplaneChartName
+
+	chart, vals, err := loadCrossplaneChart(&client.ChartPathOptions, settings, cfg)
+	if err != nil {
+		return err
+	}
+
+	// Install Crossplane
+	_, err = client.Run(chart, vals)
+	if err != nil {
+		return fmt.Errorf("failed to install Crossplane: %v", err)
+	}
+
+	return nil
+}
+
+// ensureNamespace creates the Crossplane namespace if it doesn't exist
+func (m *manager) ensureNamespace(ctx context.Context) error {
+	ns := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata": map[string]interface{}{
+				"name": m.namespace,
+			},
+		},
+	}
+
+	nsGVR := schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	_, err := m.Client.Resource(nsGVR).Create(ctx, ns, metav1.CreateOptions{})
+	if err != nil && !isAlreadyExists(err) {
+		return fmt.Errorf("failed to create namespace: %v", err)
+	}
+
+	return nil
+}
+
+// UpgradeCrossplane upgrades the Crossplane Helm release
+func (m *manager) UpgradeCrossplane(ctx context.Context, cfg config.CrossplaneConfig) error {
+	settings, actionConfig, err := m.helmConfig()
+	if err != nil {
+		return err
+	}
+
+	exists, err := releaseExists(actionConfig)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("crossplane is not installed")
+	}
+
+	return upgradeCrossplane(settings, actionConfig, cfg)
+}
+
+// upgradeCrossplane upgrades an existing Crossplane release
+func upgradeCrossplane(settings *cli.EnvSettings, actionConfig *action.Configuration, cfg config.CrossplaneConfig) error {
+	client := action.NewUpgrade(actionConfig)
+	client.Namespace = settings.Namespace()
+	client.Wait = true
+	client.Timeout = CrossplaneHelmTimeout
+
+	chart, vals, err := loadCrossplaneChart(&client.ChartPathOptions, settings, cfg)
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.Run(CrossplaneChartName, chart, vals); err != nil {
+		return fmt.Errorf("failed to upgrade Crossplane: %v", err)
+	}
+
+	return nil
+}
+
+// UninstallCrossplane removes the Crossplane Helm release. With
+// RemovePackages, configurations, functions and providers are deleted first,
+// in that order, and the Crossplane CRDs are removed once the release is gone.
+func (m *manager) UninstallCrossplane(ctx context.Context, opts UninstallOptions) error {
+	_, actionConfig, err := m.helmConfig()
+	if err != nil {
+		return err
+	}
+
+	exists, err := releaseExists(actionConfig)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("crossplane is not installed")
+	}
+
+	if opts.RemovePackages {
+		// Packages must go while Crossplane is still running to release their finalizers
+		if err := m.removePackages(ctx); err != nil {
+			return err
+		}
+	}
+
+	client := action.NewUninstall(actionConfig)
+	client.Wait = true
+	client.Timeout = CrossplaneHelmTimeout
+	if _, err := client.Run(CrossplaneChartName); err != nil {
+		return fmt.Errorf("failed to uninstall Crossplane: %v", err)
+	}
+
+	if opts.RemovePackages {
+		if err := m.removeCrossplaneCRDs(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removePackages deletes every installed package, dependants first, and
+// waits for each kind to be gone before moving on to the next
+func (m *manager) removePackages(ctx context.Context) error {
+	for i := len(config.PackageKinds) - 1; i >= 0; i-- {
+		kind := config.PackageKinds[i]
+
+		packages, err := m.ListPackages(ctx, kind)
+		if err != nil {
+			return err
+		}
+		if len(packages) == 0 {
+			continue
+		}
+
+		for _, p := range packages {
+			fmt.Printf("Deleting %s %s...\n", strings.ToLower(string(kind)), p.Name)
+			if err := m.DeletePackage(ctx, kind, p.Name); err != nil {
+				return err
+			}
+		}
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, ProviderTimeout)
+		err = m.waiter(packageGVR(kind), "").until(timeoutCtx, func(objects []*unstructured.Unstructured) bool {
+			return len(objects) == 0
+		})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed waiting for %ss to be deleted: %v", strings.ToLower(string(kind)), err)
+		}
+	}
+
+	return nil
+}
+
+// removeCrossplaneCRDs deletes the CRDs of the Crossplane API groups
+func (m *manager) removeCrossplaneCRDs(ctx context.Context) error {
+	list, err := m.Client.Resource(crdGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list CRDs: %v", err)
+	}
+
+	for _, crd := range list.Items {
+		group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+		if group != "crossplane.io" && !strings.HasSuffix(group, ".crossplane.io") {
+			continue
+		}
+
+		fmt.Printf("Deleting CRD %s...\n", crd.GetName())
+		err := m.Client.Resource(crdGVR).Delete(ctx, crd.GetName(), metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete CRD %s: %v", crd.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+// helmConfig initializes the Helm settings and action configuration for the
+// Crossplane namespace of the manager's cluster
+func (m *manager) helmConfig() (*cli.EnvSettings, *action.Configuration, error) {
+	settings := m.factory.HelmSettings()
+	settings.SetNamespace(m.namespace)
+
+	actionConfig := new(action.Configuration)
+	err := actionConfig.Init(settings.RESTClientGetter(), m.namespace, "secret", func(format string, v ...interface{}) {
+		fmt.Printf(format, v...)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize helm configuration: %v", err)
+	}
+
+	actionConfig.RegistryClient, err = newRegistryClient(settings)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return settings, actionConfig, nil
+}
+
+// releaseExists reports whether the Crossplane Helm release is installed
+func releaseExists(actionConfig *action.Configuration) (bool, error) {
+	history := action.NewHistory(actionConfig)
+	history.Max = 1
+	_, err := history.Run(CrossplaneChartName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get Crossplane release: %v", err)
+	}
+	return true, nil
+}
+
+// loadCrossplaneChart locates and loads the Crossplane chart and merges its values
+func loadCrossplaneChart(pathOptions *action.ChartPathOptions, settings *cli.EnvSettings, cfg config.CrossplaneConfig) (*chart.Chart, map[string]interface{}, error) {
+	vals, err := crossplaneValues(cfg, getter.All(settings))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chartPath, err := locateCrossplaneChart(pathOptions, settings, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chart, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load Crossplane chart: %v", err)
+	}
+
+	return chart, vals, nil
+}
+
+// locateCrossplaneChart returns the path of the Crossplane chart. Local
+// charts are used as is. A pinned version is taken from the crosslab cache
+// when it has been cached; otherwise the chart is downloaded, falling back to
+// the latest cached version when the repository is unreachable.
+func locateCrossplaneChart(pathOptions *action.ChartPathOptions, settings *cli.EnvSettings, cfg config.CrossplaneConfig) (string, error) {
+	repoURL, chartName := crossplaneChart(cfg)
+
+	if cfg.LocalChart() {
+		if _, err := os.Stat(chartName); err != nil {
+			return "", fmt.Errorf("failed to find local Crossplane chart: %v", err)
+		}
+		return chartName, nil
+	}
+
+	cacheName := path.Base(chartName)
+	if cfg.Version != "" {
+		if cached, ok := cache.FindChart(cacheName, cfg.Version); ok {
+			fmt.Printf("Using cached Crossplane chart %s\n", cached)
+			return cached, nil
+		}
+	}
+
+	if !registry.IsOCI(chartName) {
+		pathOptions.RepoURL = repoURL
+	}
+	pathOptions.Version = cfg.Version
+
+	chartPath, err := pathOptions.LocateChart(chartName, settings)
+	if err != nil {
+		if cfg.Version == "" {
+			if cached, ok := cache.FindChart(cacheName, ""); ok {
+				fmt.Printf("Failed to fetch the Crossplane chart (%v), using cached chart %s\n", err, cached)
+				return cached, nil
+			}
+		}
+		return "", fmt.Errorf("failed to locate Crossplane chart: %v", err)
+	}
+
+	return chartPath, nil
+}
+
+// PullCrossplaneChart downloads the configured Crossplane chart into the
+// crosslab cache and returns the path of the cached archive
+func PullCrossplaneChart(cfg config.CrossplaneConfig) (string, error) {
+	if cfg.LocalChart() {
+		return "", fmt.Errorf("chart %s is a local path and does not need caching", cfg.Chart)
+	}
+
+	repoURL, chartName := crossplaneChart(cfg)
+
+	dir, err := cache.ChartsDir()
+	if err != nil {
+		return "", err
+	}
+
+	settings := cli.New()
+	registryClient, err := newRegistryClient(settings)
+	if err != nil {
+		return "", err
+	}
+
+	client := action.NewPullWithOpts(action.WithConfig(&action.Configuration{RegistryClient: registryClient}))
+	client.Settings = settings
+	client.Version = cfg.Version
+	client.DestDir = dir
+	if !registry.IsOCI(chartName) {
+		client.RepoURL = repoURL
+	}
+
+	if _, err := client.Run(chartName); err != nil {
+		return "", fmt.Errorf("failed to pull Crossplane chart: %v", err)
+	}
+
+	cached, ok := cache.FindChart(path.Base(chartName), cfg.Version)
+	if !ok {
+		return "", fmt.Errorf("pulled Crossplane chart not found in %s", dir)
+	}
+
+	return cached, nil
+}
+
+// newRegistryClient creates the client used to pull charts from OCI registries
+func newRegistryClient(settings *cli.EnvSettings) (*registry.Client, error) {
+	client, err := registry.NewClient(registry.ClientOptCredentialsFile(settings.RegistryConfig))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create registry client: %v", err)
+	}
+	return client, nil
+}
+
+// crossplaneChart returns the repository URL and chart name to install,
+// falling back to the stable Crossplane chart
+func crossplaneChart(cfg config.CrossplaneConfig) (string, string) {
+	repoURL, chartName := cfg.Repo, cfg.Chart
+	if repoURL == "" {
+		repoURL = CrossplaneHelmRepo
+	}
+	if chartName == "" {
+		chartName = CrossplaneChartName
+	}
+	return repoURL, chartName
+}
+
+// crossplaneValues merges the Helm values of the Crossplane release. Values
+// files are applied first, then inline values, then --set style overrides.
+func crossplaneValues(cfg config.CrossplaneConfig, providers getter.Providers) (map[string]interface{}, error) {
+	opts := values.Options{ValueFiles: cfg.ValuesFiles}
+	vals, err := opts.MergeValues(providers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Crossplane values files: %v", err)
+	}
+
+	if len(cfg.Values) > 0 {
+		vals = chartutil.CoalesceTables(copyValues(cfg.Values), vals)
+	}
+
+	for _, s := range cfg.Set {
+		if err := strvals.ParseInto(s, vals); err != nil {
+			return nil, fmt.Errorf("failed to parse Crossplane value %q: %v", s, err)
+		}
+	}
+
+	// The chart takes the names of the pull secrets
+	if len(cfg.ImagePullSecrets) > 0 {
+		secrets, _ := vals["imagePullSecrets"].([]interface{})
+		for _, name := range cfg.ImagePullSecrets {
+			if !containsValue(secrets, name) {
+				secrets = append(secrets, name)
+			}
+		}
+		vals["imagePullSecrets"] = secrets
+	}
+
+	return vals, nil
+}
+
+// containsValue reports whether a values list contains value
+func containsValue(list []interface{}, value interface{}) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// copyValues deep copies a values map so merging never modifies the configuration
+func copyValues(src map[string]interface{}) map[string]interface{} {
+	dst := make(map[string]interface{}, len(src))
+	for k, v := range src {
+		if m, ok := v.(map[string]interface{}); ok {
+			v = copyValues(m)
+		}
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,146 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kanzifucius/crosslab/pkg/cache"
+	"github.com/kanzifucius/crosslab/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/getter"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCrossplaneValues(t *testing.T) {
+	valuesFile := filepath.Join(t.TempDir(), "values.yaml")
+	err := os.WriteFile(valuesFile, []byte("replicas: 1\nargs: [--debug]\nresourcesCrossplane:\n  limits:\n    cpu: 100m\n"), 0644)
+	assert.NoError(t, err)
+
+	cfg := config.CrossplaneConfig{
+		ValuesFiles: []string{valuesFile},
+		Values: map[string]interface{}{
+			"replicas":            2,
+			"resourcesCrossplane": map[string]interface{}{"limits": map[string]interface{}{"memory": "512Mi"}},
+		},
+		Set: []string{"replicas=3", "args={--enable-usages}"},
+	}
+
+	vals, err := crossplaneValues(cfg, getter.All(cli.New()))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), vals["replicas"])
+	assert.Equal(t, []interface{}{"--enable-usages"}, vals["args"])
+	assert.Equal(t, map[string]interface{}{"cpu": "100m", "memory": "512Mi"}, vals["resourcesCrossplane"].(map[string]interface{})["limits"])
+
+	t.Run("invalid set value", func(t *testing.T) {
+		_, err := crossplaneValues(config.CrossplaneConfig{Set: []string{"replicas"}}, getter.All(cli.New()))
+		assert.Error(t, err)
+	})
+
+	t.Run("image pull secrets", func(t *testing.T) {
+		vals, err := crossplaneValues(config.CrossplaneConfig{
+			Values:           map[string]interface{}{"imagePullSecrets": []interface{}{"dockerhub"}},
+			ImagePullSecrets: []string{"registry-example-com", "dockerhub"},
+		}, getter.All(cli.New()))
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{"dockerhub", "registry-example-com"}, vals["imagePullSecrets"])
+	})
+
+	t.Run("default chart", func(t *testing.T) {
+		repoURL, chart := crossplaneChart(config.CrossplaneConfig{})
+		assert.Equal(t, CrossplaneHelmRepo, repoURL)
+		assert.Equal(t, CrossplaneChartName, chart)
+	})
+}
+
+func TestLocateCrossplaneChart(t *testing.T) {
+	t.Setenv(cache.DirEnv, t.TempDir())
+	t.Setenv("HELM_CACHE_HOME", t.TempDir())
+	settings := cli.New()
+
+	charts, err := cache.ChartsDir()
+	assert.NoError(t, err)
+	cached := filepath.Join(charts, "crossplane-1.19.0.tgz")
+	assert.NoError(t, os.WriteFile(cached, nil, 0644))
+
+	t.Run("pinned version from cache", func(t *testing.T) {
+		path, err := locateCrossplaneChart(&action.ChartPathOptions{}, settings, config.CrossplaneConfig{Version: "1.19.0"})
+		assert.NoError(t, err)
+		assert.Equal(t, cached, path)
+	})
+
+	t.Run("latest cached version when the repository is unreachable", func(t *testing.T) {
+		path, err := locateCrossplaneChart(&action.ChartPathOptions{}, settings, config.CrossplaneConfig{Repo: "http://127.0.0.1:1"})
+		assert.NoError(t, err)
+		assert.Equal(t, cached, path)
+	})
+
+	t.Run("uncached pinned version with an unreachable repository", func(t *testing.T) {
+		_, err := locateCrossplaneChart(&action.ChartPathOptions{}, settings, config.CrossplaneConfig{Repo: "http://127.0.0.1:1", Version: "1.18.0"})
+		assert.Error(t, err)
+	})
+
+	t.Run("local chart", func(t *testing.T) {
+		path, err := locateCrossplaneChart(&action.ChartPathOptions{}, settings, config.CrossplaneConfig{Chart: cached})
+		assert.NoError(t, err)
+		assert.Equal(t, cached, path)
+
+		_, err = locateCrossplaneChart(&action.ChartPathOptions{}, settings, config.CrossplaneConfig{Chart: "./missing"})
+		assert.Error(t, err)
+	})
+}
+
+func TestRemoveCrossplaneResources(t *testing.T) {
+	crd := func(name, group string) *unstructured.Unstructured {
+		obj := newCRDObject(name, "")
+		_ = unstructured.SetNestedField(obj.Object, group, "spec", "group")
+		return obj
+	}
+
+	client := newFakeClient(
+		newPackageObject("Provider", "provider-aws-s3", map[string]interface{}{"package": "xpkg.upbound.io/upbound/provider-aws-s3:v1"}, nil),
+		newPackageObject("Function", "function-go-templating", map[string]interface{}{"package": "xpkg.upbound.io/crossplane-contrib/function-go-templating:v0.9.2"}, nil),
+		newPackageObject("Configuration", "platform-ref-aws", map[string]interface{}{"package": "xpkg.upbound.io/upbound/platform-ref-aws:v1.4.0"}, nil),
+		crd("providers.pkg.crossplane.io", "pkg.crossplane.io"),
+		crd("compositions.apiextensions.crossplane.io", "apiextensions.crossplane.io"),
+		crd("buckets.s3.aws.upbound.io", "s3.aws.upbound.io"),
+	)
+	m := &manager{Client: client}
+	ctx := context.Background()
+
+	assert.NoError(t, m.removePackages(ctx))
+
+	var deleted []string
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "delete" {
+			deleted = append(deleted, action.GetResource().Resource)
+		}
+	}
+	assert.Equal(t, []string{"configurations", "functions", "providers"}, deleted)
+
+	assert.NoError(t, m.removeCrossplaneCRDs(ctx))
+
+	crds, err := client.Resource(crdGVR).List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, crds.Items, 1)
+	assert.Equal(t, "buckets.s3.aws.upbound.io", crds.Items[0].GetName())
+}
+
+func TestInstallCrossplaneExistingNamespace(t *testing.T) {
+	namespace := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata":   map[string]interface{}{"name": CrossplaneNamespace},
+		},
+	}
+	m := &manager{Client: newFakeClient(namespace), namespace: CrossplaneNamespace}
+
+	// A re-run finds the namespace and goes on to upgrade the existing release
+	assert.NoError(t, m.ensureNamespace(context.Background()))
+	assert.NoError(t, m.ensureNamespace(context.Background()))
+}
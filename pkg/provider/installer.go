@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+)
+
+// DefaultInstallWorkers is the default number of packages installed concurrently
+const DefaultInstallWorkers = 4
+
+// InstallFunc installs a single package and waits for it to become healthy
+type InstallFunc func(ctx context.Context, pkg config.Package) error
+
+// InstallResult is the outcome of installing a single package
+type InstallResult struct {
+	Package  config.Package
+	Err      error
+	Skipped  bool
+	Duration time.Duration
+}
+
+// InstallAll installs packages concurrently with at most workers installs in
+// flight. A package starts only once every package it depends on has been
+// installed successfully; packages whose dependencies failed are skipped.
+// Failures do not abort the other installs: a result is returned for every
+// package, in the order given, and the returned error joins all failures.
+func InstallAll(ctx context.Context, packages []config.Package, workers int, install InstallFunc) ([]InstallResult, error) {
+	if err := config.CheckDependencies(packages); err != nil {
+		return nil, err
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]InstallResult, len(packages))
+	done := make(map[string]chan struct{}, len(packages))
+	for _, p := range packages {
+		done[p.Name] = make(chan struct{})
+	}
+
+	var (
+		mu     sync.Mutex
+		failed = map[string]bool{}
+		sem    = make(chan struct{}, workers)
+		wg     sync.WaitGroup
+	)
+
+	for i, p := range packages {
+		wg.Add(1)
+		go func(i int, p config.Package) {
+			defer wg.Done()
+			defer close(done[p.Name])
+
+			result := InstallResult{Package: p}
+			defer func() {
+				mu.Lock()
+				results[i] = result
+				if result.Err != nil {
+					failed[p.Name] = true
+				}
+				mu.Unlock()
+			}()
+
+			for _, dep := range p.DependsOn {
+				<-done[dep]
+
+				mu.Lock()
+				depFailed := failed[dep]
+				mu.Unlock()
+
+				if depFailed {
+					result.Skipped = true
+					result.Err = fmt.Errorf("skipped %s: dependency %s failed", p.Name, dep)
+					return
+				}
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				result.Err = fmt.Errorf("install of %s cancelled: %v", p.Name, ctx.Err())
+				return
+			}
+			defer func() { <-sem }()
+
+			start := time.Now()
+			result.Err = install(ctx, p)
+			result.Duration = time.Since(start)
+		}(i, p)
+	}
+
+	wg.Wait()
+
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil && !r.Skipped {
+			errs = append(errs, r.Err)
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,127 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPackage(name string, dependsOn ...string) config.Package {
+	return config.Package{
+		Kind:     config.ProviderKind,
+		Provider: config.Provider{Name: name, Package: "xpkg.upbound.io/upbound/" + name, Version: "v1", DependsOn: dependsOn},
+	}
+}
+
+func TestInstallAll(t *testing.T) {
+	t.Run("dependencies are installed first", func(t *testing.T) {
+		packages := []config.Package{
+			newTestPackage("provider-aws-s3", "upbound-provider-aws"),
+			newTestPackage("provider-aws-iam", "upbound-provider-aws"),
+			newTestPackage("upbound-provider-aws"),
+			newTestPackage("provider-helm"),
+		}
+
+		var mu sync.Mutex
+		finished := map[string]bool{}
+		results, err := InstallAll(context.Background(), packages, 4, func(ctx context.Context, p config.Package) error {
+			mu.Lock()
+			for _, dep := range p.DependsOn {
+				assert.True(t, finished[dep], "%s started before %s finished", p.Name, dep)
+			}
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			finished[p.Name] = true
+			mu.Unlock()
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, results, 4)
+		for i, r := range results {
+			assert.Equal(t, packages[i].Name, r.Package.Name)
+			assert.NoError(t, r.Err)
+		}
+	})
+
+	t.Run("worker limit is respected", func(t *testing.T) {
+		var packages []config.Package
+		for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+			packages = append(packages, newTestPackage(name))
+		}
+
+		var inFlight, maxInFlight int32
+		_, err := InstallAll(context.Background(), packages, 2, func(ctx context.Context, p config.Package) error {
+			n := atomic.AddInt32(&inFlight, 1)
+			for {
+				m := atomic.LoadInt32(&maxInFlight)
+				if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, maxInFlight, int32(2))
+	})
+
+	t.Run("failures are aggregated and dependants skipped", func(t *testing.T) {
+		packages := []config.Package{
+			newTestPackage("upbound-provider-aws"),
+			newTestPackage("provider-aws-s3", "upbound-provider-aws"),
+			newTestPackage("provider-helm"),
+			newTestPackage("provider-kubernetes"),
+		}
+
+		results, err := InstallAll(context.Background(), packages, 2, func(ctx context.Context, p config.Package) error {
+			if p.Name == "upbound-provider-aws" || p.Name == "provider-helm" {
+				return errors.New("install failed: " + p.Name)
+			}
+			return nil
+		})
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "upbound-provider-aws")
+		assert.Contains(t, err.Error(), "provider-helm")
+		assert.True(t, results[1].Skipped)
+		assert.NoError(t, results[3].Err)
+	})
+
+	t.Run("dependency cycle", func(t *testing.T) {
+		packages := []config.Package{
+			newTestPackage("a", "b"),
+			newTestPackage("b", "a"),
+		}
+
+		_, err := InstallAll(context.Background(), packages, 2, func(ctx context.Context, p config.Package) error {
+			return nil
+		})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "cycle")
+	})
+
+	t.Run("duplicate names", func(t *testing.T) {
+		packages := []config.Package{
+			newTestPackage("provider-helm"),
+			newTestPackage("provider-helm"),
+		}
+
+		_, err := InstallAll(context.Background(), packages, 2, func(ctx context.Context, p config.Package) error {
+			return nil
+		})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "duplicate package name provider-helm")
+	})
+}
@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// LocalStackName is the name of the LocalStack deployment and service
+const LocalStackName = "localstack"
+
+// serviceGVR is the GroupVersionResource of Services
+var serviceGVR = schema.GroupVersionResource{
+	Version:  "v1",
+	Resource: "services",
+}
+
+// DeployLocalStack deploys LocalStack, or the configured stand-in image, into
+// the Crossplane namespace, waits for it to become available and returns its
+// in-cluster endpoint
+func (m *manager) DeployLocalStack(ctx context.Context, cfg config.LocalStackConfig) (string, error) {
+	deployment, service := localStackObjects(m.namespace, cfg)
+	if err := m.apply(ctx, deploymentGVR, m.namespace, deployment); err != nil {
+		return "", fmt.Errorf("failed to apply LocalStack deployment: %v", err)
+	}
+
+	if err := m.applyService(ctx, service); err != nil {
+		return "", fmt.Errorf("failed to apply LocalStack service: %v", err)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, ProviderTimeout)
+	defer cancel()
+
+	if err := m.waiter(deploymentGVR, m.namespace).WaitForCondition(timeoutCtx, "Available", printTransition, LocalStackName); err != nil {
+		if timeoutCtx.Err() != nil {
+			return "", fmt.Errorf("timeout waiting for LocalStack to become available")
+		}
+		return "", fmt.Errorf("failed waiting for LocalStack: %v", err)
+	}
+
+	return LocalStackEndpoint(m.namespace, cfg), nil
+}
+
+// localStackObjects builds the Deployment and Service of the stand-in
+func localStackObjects(namespace string, cfg config.LocalStackConfig) (*unstructured.Unstructured, *unstructured.Unstructured) {
+	port := int64(cfg.PortOrDefault())
+	labels := map[string]interface{}{"app.kubernetes.io/name": LocalStackName}
+
+	names := make([]string, 0, len(cfg.Env))
+	for name := range cfg.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	env := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		env = append(env, map[string]interface{}{"name": name, "value": cfg.Env[name]})
+	}
+
+	deployment := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name":      LocalStackName,
+				"namespace": namespace,
+				"labels":    labels,
+			},
+			"spec": map[string]interface{}{
+				"replicas": int64(1),
+				"selector": map[string]interface{}{"matchLabels": labels},
+				"template": map[string]interface{}{
+					"metadata": map[string]interface{}{"labels": labels},
+					"spec": map[string]interface{}{
+						"containers": []interface{}{
+							map[string]interface{}{
+								"name":  LocalStackName,
+								"image": cfg.ImageOrDefault(),
+								"env":   env,
+								"ports": []interface{}{
+									map[string]interface{}{"name": "edge", "containerPort": port},
+								},
+								"readinessProbe": map[string]interface{}{
+									"tcpSocket": map[string]interface{}{"port": port},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	service := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Service",
+			"metadata": map[string]interface{}{
+				"name":      LocalStackName,
+				"namespace": namespace,
+				"labels":    labels,
+			},
+			"spec": map[string]interface{}{
+				"selector": labels,
+				"ports": []interface{}{
+					map[string]interface{}{"name": "edge", "port": port, "targetPort": port},
+				},
+			},
+		},
+	}
+	return deployment, service
+}
+
+// applyService creates a Service, or replaces it keeping the cluster IP the
+// API server allocated
+func (m *manager) applyService(ctx context.Context, service *unstructured.Unstructured) error {
+	existing, err := m.Client.Resource(serviceGVR).Namespace(service.GetNamespace()).Get(ctx, service.GetName(), metav1.GetOptions{})
+	if err == nil {
+		if clusterIP, found, _ := unstructured.NestedString(existing.Object, "spec", "clusterIP"); found {
+			unstructured.SetNestedField(service.Object, clusterIP, "spec", "clusterIP")
+		}
+	}
+	return m.apply(ctx, serviceGVR, service.GetNamespace(), service)
+}
+
+// LocalStackEndpoint returns the in-cluster URL of the stand-in
+func LocalStackEndpoint(namespace string, cfg config.LocalStackConfig) string {
+	return fmt.Sprintf("http://%s.%s.svc.cluster.local:%d", LocalStackName, namespace, cfg.PortOrDefault())
+}
@@ -0,0 +1,27 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestLocalStackObjects(t *testing.T) {
+	cfg := config.LocalStackConfig{Image: "motoserver/moto:5.0.0", Port: 5000, Env: map[string]string{"MOTO_PORT": "5000"}}
+	deployment, service := localStackObjects(CrossplaneNamespace, cfg)
+
+	containers, _, _ := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "containers")
+	container := containers[0].(map[string]interface{})
+	assert.Equal(t, "motoserver/moto:5.0.0", container["image"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "MOTO_PORT", "value": "5000"}}, container["env"])
+	assert.Equal(t, CrossplaneNamespace, deployment.GetNamespace())
+
+	ports, _, _ := unstructured.NestedSlice(service.Object, "spec", "ports")
+	assert.Equal(t, int64(5000), ports[0].(map[string]interface{})["port"])
+
+	assert.Equal(t, "http://localstack.crossplane-system.svc.cluster.local:5000", LocalStackEndpoint(CrossplaneNamespace, cfg))
+	assert.Equal(t, "http://localstack.lab.svc.cluster.local:4566", LocalStackEndpoint("lab", config.LocalStackConfig{}))
+}
@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	kindcluster "github.com/kanzifucius/crosslab/pkg/kind"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// lockGVR is the GroupVersionResource of the Lock in which Crossplane's
+// package manager records the dependencies of every installed package
+var lockGVR = schema.GroupVersionResource{
+	Group:    "pkg.crossplane.io",
+	Version:  "v1beta1",
+	Resource: "locks",
+}
+
+// lockName is the name of Crossplane's only Lock
+const lockName = "lock"
+
+// Dependencies returns the packages other installed packages depend on, as
+// recorded in Crossplane's Lock. Crossplane installs these itself.
+func (m *manager) Dependencies(ctx context.Context) ([]string, error) {
+	lock, err := m.Client.Resource(lockGVR).Get(ctx, lockName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package lock: %v", err)
+	}
+
+	packages, _, _ := unstructured.NestedSlice(lock.Object, "packages")
+	var dependencies []string
+	for _, p := range packages {
+		entry, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		deps, _, _ := unstructured.NestedSlice(entry, "dependencies")
+		for _, d := range deps {
+			dep, ok := d.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if pkg, ok := dep["package"].(string); ok && pkg != "" {
+				dependencies = append(dependencies, kindcluster.HostRef(pkg))
+			}
+		}
+	}
+
+	return dependencies, nil
+}
+
+// isDependency reports whether a package, without version, is one of the
+// dependencies, once the default registry is filled in
+func isDependency(pkg string, dependencies []string) bool {
+	repo, err := name.NewRepository(pkg, name.WithDefaultRegistry(DefaultPackageRegistry))
+	if err != nil {
+		return false
+	}
+	for _, dep := range dependencies {
+		depRepo, err := name.NewRepository(dep, name.WithDefaultRegistry(DefaultPackageRegistry))
+		if err == nil && depRepo.Name() == repo.Name() {
+			return true
+		}
+	}
+	return false
+}
@@ -2,57 +2,132 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/kanzifucius/crosslab/pkg/config"
+	kindcluster "github.com/kanzifucius/crosslab/pkg/kind"
+	"github.com/kanzifucius/crosslab/pkg/kube"
 
-	"helm.sh/helm/v3/pkg/action"
-	"helm.sh/helm/v3/pkg/chart/loader"
-	"helm.sh/helm/v3/pkg/cli"
-	"helm.sh/helm/v3/pkg/getter"
-	"helm.sh/helm/v3/pkg/repo"
+	"github.com/google/go-containerregistry/pkg/name"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
 const (
+	// PackageLabel is the label Crossplane sets on package revisions to reference their package
+	PackageLabel = "pkg.crossplane.io/package"
+
+	// CrossplaneNamespace is the default namespace Crossplane is installed into
 	CrossplaneNamespace = "crossplane-system"
 	ProviderTimeout     = 300 * time.Second
 	CrossplaneHelmRepo  = "https://charts.crossplane.io/stable"
 	CrossplaneChartName = "crossplane"
+	// CrossplaneDeployment is the name of the Crossplane core deployment
+	CrossplaneDeployment = "crossplane"
+	// DefaultPackageRegistry is the registry Crossplane pulls packages from
+	// when their reference names none
+	DefaultPackageRegistry = "xpkg.upbound.io"
+
+	// defaultPullPolicy and defaultRevisionHistoryLimit are the values
+	// Crossplane uses for packages that do not set them
+	defaultPullPolicy           = "IfNotPresent"
+	defaultRevisionHistoryLimit = 1
 )
 
+// deploymentGVR is the GroupVersionResource of Deployments
+var deploymentGVR = schema.GroupVersionResource{
+	Group:    "apps",
+	Version:  "v1",
+	Resource: "deployments",
+}
+
 // Manager defines the interface for provider operations
 type Manager interface {
-	// InstallCrossplane installs the Crossplane Helm chart
-	InstallCrossplane(ctx context.Context) error
+	// InstallCrossplane installs the Crossplane Helm chart, or upgrades an existing release
+	InstallCrossplane(ctx context.Context, cfg config.CrossplaneConfig) error
+	// UpgradeCrossplane upgrades the Crossplane Helm release
+	UpgradeCrossplane(ctx context.Context, cfg config.CrossplaneConfig) error
+	// UninstallCrossplane removes the Crossplane Helm release
+	UninstallCrossplane(ctx context.Context, opts UninstallOptions) error
 	// WaitForCrossplaneHealth waits for Crossplane to become healthy
 	WaitForCrossplaneHealth(ctx context.Context) error
+	// CrossplaneStatus returns the state of the Crossplane release and deployment
+	CrossplaneStatus(ctx context.Context) (*CrossplaneStatus, error)
+	// ServerVersion returns the Kubernetes version of the cluster, checking that its API is reachable
+	ServerVersion(ctx context.Context) (string, error)
 	// Install installs or updates a Crossplane provider
 	Install(ctx context.Context, provider config.Provider, force bool) error
+	// InstallPackage installs or updates a Crossplane package of the given kind
+	InstallPackage(ctx context.Context, kind config.PackageKind, pkg config.Provider, force bool) error
+	// Upgrade upgrades an existing package in place and waits for its new revision to become active and healthy
+	Upgrade(ctx context.Context, kind config.PackageKind, pkg config.Provider) error
+	// Revision returns the name of the package revision built from the configured reference, or an empty string if there is none
+	Revision(ctx context.Context, kind config.PackageKind, pkg config.Provider) (string, error)
+	// Drifted reports whether the options or runtime configuration of an installed package differ from the configuration
+	Drifted(ctx context.Context, kind config.PackageKind, pkg config.Provider) (bool, error)
+	// GetPackage returns the installed package of the given kind, or nil if it is not installed
+	GetPackage(ctx context.Context, kind config.PackageKind, name string) (*config.Provider, error)
 	// WaitForHealth waits for a provider to become healthy
 	WaitForHealth(ctx context.Context, name string) error
+	// WaitForPackageHealth waits for a Crossplane package of the given kind to become healthy
+	WaitForPackageHealth(ctx context.Context, kind config.PackageKind, name string) error
 	// List returns a list of installed Crossplane providers
 	List(ctx context.Context) ([]config.Provider, error)
+	// ListPackages returns the installed Crossplane packages of the given kind
+	ListPackages(ctx context.Context, kind config.PackageKind) ([]config.Provider, error)
+	// Status returns the status of an installed package, or nil if it is not installed
+	Status(ctx context.Context, kind config.PackageKind, name string) (*PackageStatus, error)
+	// ListStatus returns the status of every installed package of the given kind
+	ListStatus(ctx context.Context, kind config.PackageKind) ([]PackageStatus, error)
 	// Delete deletes a Crossplane provider
 	Delete(ctx context.Context, name string) error
+	// DeletePackage deletes a Crossplane package of the given kind
+	DeletePackage(ctx context.Context, kind config.PackageKind, name string) error
 	// Exists checks if a provider already exists
 	Exists(ctx context.Context, name string) (bool, error)
+	// ApplyProviderConfig creates or updates a ProviderConfig and its credentials Secret
+	ApplyProviderConfig(ctx context.Context, pc config.ProviderConfig) error
+	// DeployLocalStack deploys an AWS stand-in into the cluster and returns its in-cluster endpoint
+	DeployLocalStack(ctx context.Context, cfg config.LocalStackConfig) (string, error)
+	// ApplyRegistrySecret creates or updates the pull secret of a private registry
+	ApplyRegistrySecret(ctx context.Context, auth config.RegistryAuth) error
+	// Dependencies returns the packages Crossplane installs as dependencies of other packages
+	Dependencies(ctx context.Context) ([]string, error)
+	// Close stops the watches started while waiting for resources
+	Close()
 }
 
 // manager handles Crossplane provider operations
 type manager struct {
 	Client dynamic.Interface
+
+	// factory selects the cluster, so that Helm and the dynamic client target the same one
+	factory *kube.ClientFactory
+	// namespace is the namespace Crossplane is installed into
+	namespace string
+
+	waitersMu sync.Mutex
+	waiters   map[waiterKey]*Waiter
 }
 
-// NewManager creates a new provider manager
-func NewManager() (Manager, error) {
-	config, err := getKubeConfig()
+// waiterKey identifies the shared waiter of a resource in a namespace
+type waiterKey struct {
+	gvr       schema.GroupVersionResource
+	namespace string
+}
+
+// NewManager creates a new provider manager for the cluster of a client factory
+func NewManager(factory *kube.ClientFactory) (Manager, error) {
+	config, err := factory.RESTConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -62,47 +137,74 @@ func NewManager() (Manager, error) {
 		return nil, fmt.Errorf("failed to create dynamic client: %v", err)
 	}
 
-	return &manager{Client: client}, nil
+	namespace := factory.Namespace
+	if namespace == "" {
+		namespace = CrossplaneNamespace
+	}
+
+	return &manager{Client: client, factory: factory, namespace: namespace}, nil
 }
 
-// getKubeConfig returns a Kubernetes REST config
-func getKubeConfig() (*rest.Config, error) {
-	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	configOverrides := &clientcmd.ConfigOverrides{}
-	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
+// ServerVersion returns the Kubernetes version of the cluster, checking that its API is reachable
+func (m *manager) ServerVersion(ctx context.Context) (string, error) {
+	config, err := m.factory.RESTConfig()
+	if err != nil {
+		return "", err
+	}
 
-	config, err := kubeConfig.ClientConfig()
+	client, err := discovery.NewDiscoveryClientForConfig(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get Kubernetes config: %v", err)
+		return "", fmt.Errorf("failed to create discovery client: %v", err)
 	}
 
-	return config, nil
+	version, err := client.ServerVersion()
+	if err != nil {
+		return "", fmt.Errorf("failed to reach the Kubernetes API at %s: %v", config.Host, err)
+	}
+
+	return version.GitVersion, nil
 }
 
-// Delete deletes a Crossplane provider
-func (m *manager) Delete(ctx context.Context, providerName string) error {
-	providerGVR := schema.GroupVersionResource{
-		Group:    "pkg.crossplane.io",
-		Version:  "v1",
-		Resource: "providers",
+// packageGVR returns the GroupVersionResource for a Crossplane package kind
+func packageGVR(kind config.PackageKind) schema.GroupVersionResource {
+	gvr := schema.GroupVersionResource{
+		Group:   "pkg.crossplane.io",
+		Version: "v1",
 	}
 
-	if err := m.Client.Resource(providerGVR).Delete(ctx, providerName, metav1.DeleteOptions{}); err != nil {
-		return fmt.Errorf("failed to delete provider %s: %v", providerName, err)
+	switch kind {
+	case config.FunctionKind:
+		gvr.Resource = "functions"
+	case config.ConfigurationKind:
+		gvr.Resource = "configurations"
+	default:
+		gvr.Resource = "providers"
 	}
 
-	return nil
+	return gvr
+}
+
+// packageRevisionGVR returns the GroupVersionResource for the revisions of a
+// Crossplane package kind, e.g. providerrevisions
+func packageRevisionGVR(kind config.PackageKind) schema.GroupVersionResource {
+	gvr := packageGVR(kind)
+	gvr.Resource = strings.TrimSuffix(gvr.Resource, "s") + "revisions"
+	return gvr
+}
+
+// Delete deletes a Crossplane provider
+func (m *manager) Delete(ctx context.Context, providerName string) error {
+	return m.DeletePackage(ctx, config.ProviderKind, providerName)
 }
 
 // Exists checks if a provider already exists
 func (m *manager) Exists(ctx context.Context, providerName string) (bool, error) {
-	providerGVR := schema.GroupVersionResource{
-		Group:    "pkg.crossplane.io",
-		Version:  "v1",
-		Resource: "providers",
-	}
+	return m.packageExists(ctx, config.ProviderKind, providerName)
+}
 
-	_, err := m.Client.Resource(providerGVR).Get(ctx, providerName, metav1.GetOptions{})
+// packageExists checks if a package of the given kind already exists
+func (m *manager) packageExists(ctx context.Context, kind config.PackageKind, name string) (bool, error) {
+	_, err := m.Client.Resource(packageGVR(kind)).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
 		return false, nil
 	}
@@ -110,256 +212,452 @@ func (m *manager) Exists(ctx context.Context, providerName string) (bool, error)
 	return true, nil
 }
 
+// DeletePackage deletes a Crossplane package of the given kind
+func (m *manager) DeletePackage(ctx context.Context, kind config.PackageKind, name string) error {
+	if err := m.Client.Resource(packageGVR(kind)).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+		return fmt.Errorf("failed to delete %s %s: %v", strings.ToLower(string(kind)), name, err)
+	}
+
+	return nil
+}
+
 // Install installs or updates a Crossplane provider
 func (m *manager) Install(ctx context.Context, provider config.Provider, force bool) error {
-	exists, err := m.Exists(ctx, provider.Name)
+	return m.InstallPackage(ctx, config.ProviderKind, provider, force)
+}
+
+// InstallPackage installs or updates a Crossplane package of the given kind.
+// With the Manual revision activation policy the first revision of a new
+// package stays inactive and the package never becomes healthy, so
+// InstallPackage waits for that revision to be created.
+func (m *manager) InstallPackage(ctx context.Context, kind config.PackageKind, pkg config.Provider, force bool) error {
+	kindName := strings.ToLower(string(kind))
+
+	exists, err := m.packageExists(ctx, kind, pkg.Name)
 	if err != nil {
-		return fmt.Errorf("failed to check if provider exists: %v", err)
+		return fmt.Errorf("failed to check if %s exists: %v", kindName, err)
 	}
 
 	if exists {
 		if !force {
-			return fmt.Errorf("provider %s already exists, use force option to reinstall", provider.Name)
-		}
-
-		// Delete existing provider
-		if err := m.Delete(ctx, provider.Name); err != nil {
-			return fmt.Errorf("failed to delete existing provider: %v", err)
+			return fmt.Errorf("%s %s already exists, use force option to upgrade it in place", kindName, pkg.Name)
 		}
 
-		// Wait for provider to be deleted
-		for {
-			exists, err := m.Exists(ctx, provider.Name)
-			if err != nil {
-				return fmt.Errorf("failed to check if provider is deleted: %v", err)
-			}
-			if !exists {
-				break
-			}
-			time.Sleep(2 * time.Second)
-		}
+		return m.Upgrade(ctx, kind, pkg)
 	}
 
-	providerGVR := schema.GroupVersionResource{
-		Group:    "pkg.crossplane.io",
-		Version:  "v1",
-		Resource: "providers",
+	if pkg.RuntimeConfig != nil {
+		if err := m.applyRuntimeConfig(ctx, pkg); err != nil {
+			return err
+		}
 	}
 
-	providerObj := &unstructured.Unstructured{
+	gvr := packageGVR(kind)
+	pkgObj := &unstructured.Unstructured{
 		Object: map[string]interface{}{
-			"apiVersion": "pkg.crossplane.io/v1",
-			"kind":       "Provider",
+			"apiVersion": gvr.GroupVersion().String(),
+			"kind":       string(kind),
 			"metadata": map[string]interface{}{
-				"name": provider.Name,
-			},
-			"spec": map[string]interface{}{
-				"package": fmt.Sprintf("%s:%s", provider.Package, provider.Version),
+				"name": pkg.Name,
 			},
+			"spec": packageSpec(pkg),
 		},
 	}
 
-	_, err = m.Client.Resource(providerGVR).Create(ctx, providerObj, metav1.CreateOptions{})
+	_, err = m.Client.Resource(gvr).Create(ctx, pkgObj, metav1.CreateOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to create provider %s: %v", provider.Name, err)
+		return fmt.Errorf("failed to create %s %s: %v", kindName, pkg.Name, err)
+	}
+
+	if err := m.pruneRuntimeConfig(ctx, pkg); err != nil {
+		return err
 	}
 
+	if pkg.RevisionActivationPolicy == config.ManualActivation {
+		return m.waitForRevision(ctx, kind, pkg.Name, clusterRef(pkg), "", false)
+	}
 	return nil
 }
 
-// WaitForHealth waits for a provider to become healthy
-func (m *manager) WaitForHealth(ctx context.Context, name string) error {
-	providerGVR := schema.GroupVersionResource{
-		Group:    "pkg.crossplane.io",
-		Version:  "v1",
-		Resource: "providers",
+// packageSpec builds the spec of a package object from its configuration
+func packageSpec(pkg config.Provider) map[string]interface{} {
+	spec := map[string]interface{}{
+		"package": clusterRef(pkg),
+	}
+	if pkg.PackagePullPolicy != "" {
+		spec["packagePullPolicy"] = pkg.PackagePullPolicy
+	}
+	if len(pkg.PackagePullSecrets) > 0 {
+		secrets := make([]interface{}, 0, len(pkg.PackagePullSecrets))
+		for _, name := range pkg.PackagePullSecrets {
+			secrets = append(secrets, map[string]interface{}{"name": name})
+		}
+		spec["packagePullSecrets"] = secrets
+	}
+	if pkg.RevisionActivationPolicy != "" {
+		spec["revisionActivationPolicy"] = pkg.RevisionActivationPolicy
+	}
+	if pkg.RevisionHistoryLimit != nil {
+		spec["revisionHistoryLimit"] = *pkg.RevisionHistoryLimit
 	}
+	if pkg.SkipDependencyResolution {
+		spec["skipDependencyResolution"] = true
+	}
+	if pkg.RuntimeConfig != nil {
+		spec["runtimeConfigRef"] = map[string]interface{}{"name": pkg.Name}
+	}
+	return spec
+}
 
-	timeoutCtx, cancel := context.WithTimeout(ctx, ProviderTimeout)
-	defer cancel()
+// clusterRef returns the reference of a package as Crossplane pulls it,
+// pointing packages on the local registry at its in-cluster address
+func clusterRef(pkg config.Provider) string {
+	return kindcluster.ClusterRef(pkg.Ref())
+}
+
+// parsePackageRef splits the package reference of an installed package into
+// the package and its version, as written in the configuration
+func parsePackageRef(ref string) (string, string) {
+	return config.ParsePackageRef(kindcluster.HostRef(ref))
+}
 
-	for {
-		select {
-		case <-timeoutCtx.Done():
-			return fmt.Errorf("timeout waiting for provider %s to become healthy", name)
-		default:
-			provider, err := m.Client.Resource(providerGVR).Get(ctx, name, metav1.GetOptions{})
-			if err != nil {
-				return fmt.Errorf("failed to get provider %s: %v", name, err)
-			}
-
-			conditions, found, err := unstructured.NestedSlice(provider.Object, "status", "conditions")
-			if err != nil || !found {
-				continue
-			}
-
-			for _, c := range conditions {
-				condition, ok := c.(map[string]interface{})
-				if !ok {
-					continue
-				}
-
-				if condition["type"] == "Healthy" && condition["status"] == "True" {
-					return nil
-				}
-			}
-
-			time.Sleep(5 * time.Second)
+// upgradeSpec builds the merge patch of a package spec. Optional fields that
+// are no longer configured are unset, so the package falls back to
+// Crossplane's defaults.
+func upgradeSpec(pkg config.Provider) map[string]interface{} {
+	spec := packageSpec(pkg)
+	for _, field := range []string{"packagePullPolicy", "packagePullSecrets", "revisionActivationPolicy", "revisionHistoryLimit", "runtimeConfigRef"} {
+		if _, ok := spec[field]; !ok {
+			spec[field] = nil
 		}
 	}
+	spec["skipDependencyResolution"] = pkg.SkipDependencyResolution
+	return spec
 }
 
-// List returns a list of installed Crossplane providers
-func (m *manager) List(ctx context.Context) ([]config.Provider, error) {
-	providerGVR := schema.GroupVersionResource{
-		Group:    "pkg.crossplane.io",
-		Version:  "v1",
-		Resource: "providers",
+// GetPackage returns the installed package of the given kind, or nil if it is not installed
+func (m *manager) GetPackage(ctx context.Context, kind config.PackageKind, name string) (*config.Provider, error) {
+	obj, err := m.Client.Resource(packageGVR(kind)).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
 	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %s: %v", strings.ToLower(string(kind)), name, err)
+	}
+
+	ref, _, _ := unstructured.NestedString(obj.Object, "spec", "package")
+	pkg, version := parsePackageRef(ref)
+
+	return &config.Provider{
+		Name:    name,
+		Package: pkg,
+		Version: version,
+	}, nil
+}
 
-	list, err := m.Client.Resource(providerGVR).List(ctx, metav1.ListOptions{})
+// Drifted reports whether the pull and revision options or the runtime
+// configuration of an installed package differ from the configuration, so
+// that upgrading it in place would change the cluster. A package that is not
+// installed has not drifted.
+func (m *manager) Drifted(ctx context.Context, kind config.PackageKind, pkg config.Provider) (bool, error) {
+	obj, err := m.Client.Resource(packageGVR(kind)).Get(ctx, pkg.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to list providers: %v", err)
+		return false, fmt.Errorf("failed to get %s %s: %v", strings.ToLower(string(kind)), pkg.Name, err)
 	}
 
-	var providers []config.Provider
-	for _, item := range list.Items {
-		pkg, found, err := unstructured.NestedString(item.Object, "spec", "package")
-		if err != nil || !found {
-			continue
-		}
+	if optionsDrifted(obj, pkg) {
+		return true, nil
+	}
+	return m.runtimeConfigDrifted(ctx, obj, pkg)
+}
 
-		providers = append(providers, config.Provider{
-			Name:    item.GetName(),
-			Package: pkg,
-		})
+// optionsDrifted reports whether the pull and revision options of an
+// installed package differ from pkg. Unset options are compared as
+// Crossplane's defaults.
+func optionsDrifted(obj *unstructured.Unstructured, pkg config.Provider) bool {
+	pullPolicy, _, _ := unstructured.NestedString(obj.Object, "spec", "packagePullPolicy")
+	activation, _, _ := unstructured.NestedString(obj.Object, "spec", "revisionActivationPolicy")
+	skipDependencies, _, _ := unstructured.NestedBool(obj.Object, "spec", "skipDependencyResolution")
+	historyLimit, found, _ := unstructured.NestedInt64(obj.Object, "spec", "revisionHistoryLimit")
+	if !found {
+		historyLimit = defaultRevisionHistoryLimit
+	}
+	wantHistoryLimit := int64(defaultRevisionHistoryLimit)
+	if pkg.RevisionHistoryLimit != nil {
+		wantHistoryLimit = *pkg.RevisionHistoryLimit
+	}
+
+	var secrets []string
+	list, _, _ := unstructured.NestedSlice(obj.Object, "spec", "packagePullSecrets")
+	for _, item := range list {
+		if secret, ok := item.(map[string]interface{}); ok {
+			name, _ := secret["name"].(string)
+			secrets = append(secrets, name)
+		}
 	}
 
-	return providers, nil
+	return valueOr(pullPolicy, defaultPullPolicy) != valueOr(pkg.PackagePullPolicy, defaultPullPolicy) ||
+		valueOr(activation, config.AutomaticActivation) != valueOr(pkg.RevisionActivationPolicy, config.AutomaticActivation) ||
+		historyLimit != wantHistoryLimit ||
+		skipDependencies != pkg.SkipDependencyResolution ||
+		!equalStrings(secrets, pkg.PackagePullSecrets)
 }
 
-// InstallCrossplane installs the Crossplane Helm chart
-func (m *manager) InstallCrossplane(ctx context.Context) error {
-	// Create namespace if it doesn't exist
-	ns := &unstructured.Unstructured{
-		Object: map[string]interface{}{
-			"apiVersion": "v1",
-			"kind":       "Namespace",
-			"metadata": map[string]interface{}{
-				"name": CrossplaneNamespace,
-			},
-		},
+// valueOr returns value, or def if value is empty
+func valueOr(value, def string) string {
+	if value == "" {
+		return def
 	}
+	return value
+}
 
-	nsGVR := schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
-	_, err := m.Client.Resource(nsGVR).Create(ctx, ns, metav1.CreateOptions{})
-	if err != nil && !isAlreadyExists(err) {
-		return fmt.Errorf("failed to create namespace: %v", err)
+// equalStrings reports whether two lists hold the same strings in the same order
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
 	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
 
-	// Initialize Helm settings
-	settings := cli.New()
-	settings.SetNamespace(CrossplaneNamespace)
+// Upgrade upgrades an existing package in place by patching spec.package and
+// waiting for the package revision built from the new reference to become
+// active and healthy. Unlike a delete and re-create, the CRDs and managed
+// resources owned by the package are kept. With the Manual revision
+// activation policy the new revision stays inactive, so Upgrade only waits
+// for it to be created.
+func (m *manager) Upgrade(ctx context.Context, kind config.PackageKind, pkg config.Provider) error {
+	kindName := strings.ToLower(string(kind))
+	ref := clusterRef(pkg)
+
+	if pkg.RuntimeConfig != nil {
+		if err := m.applyRuntimeConfig(ctx, pkg); err != nil {
+			return err
+		}
+	}
 
-	// Add Crossplane Helm repository
-	repoEntry := repo.Entry{
-		Name: "crossplane-stable",
-		URL:  CrossplaneHelmRepo,
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": upgradeSpec(pkg),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build patch for %s %s: %v", kindName, pkg.Name, err)
 	}
 
-	// Create repository with HTTP client
-	r, err := repo.NewChartRepository(&repoEntry, getter.All(settings))
+	patched, err := m.Client.Resource(packageGVR(kind)).Patch(ctx, pkg.Name, types.MergePatchType, patch, metav1.PatchOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to create chart repository: %v", err)
+		return fmt.Errorf("failed to patch %s %s: %v", kindName, pkg.Name, err)
 	}
+	previous, _, _ := unstructured.NestedString(patched.Object, "status", "currentRevision")
 
-	if _, err := r.DownloadIndexFile(); err != nil {
-		return fmt.Errorf("failed to download repository index: %v", err)
+	if err := m.pruneRuntimeConfig(ctx, pkg); err != nil {
+		return err
 	}
 
-	// Initialize Helm action configuration
-	actionConfig := new(action.Configuration)
-	err = actionConfig.Init(settings.RESTClientGetter(), CrossplaneNamespace, "secret", func(format string, v ...interface{}) {
-		fmt.Printf(format, v...)
+	return m.waitForRevision(ctx, kind, pkg.Name, ref, previous, pkg.RevisionActivationPolicy != config.ManualActivation)
+}
+
+// Revision returns the name of the package revision built from the configured reference, or an empty string if there is none
+func (m *manager) Revision(ctx context.Context, kind config.PackageKind, pkg config.Provider) (string, error) {
+	list, err := m.Client.Resource(packageRevisionGVR(kind)).List(ctx, metav1.ListOptions{
+		LabelSelector: PackageLabel + "=" + pkg.Name,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to initialize helm configuration: %v", err)
+		return "", fmt.Errorf("failed to list %s revisions: %v", strings.ToLower(string(kind)), err)
 	}
 
-	// Create Helm install client
-	client := action.NewInstall(actionConfig)
-	client.Namespace = CrossplaneNamespace
-	client.CreateNamespace = true
-	client.Wait = true
-	client.Timeout = 5 * time.Minute
-	client.ReleaseName = CrossplaneChartName
-	client.ChartPathOptions.RepoURL = CrossplaneHelmRepo
+	ref := clusterRef(pkg)
+	for _, rev := range list.Items {
+		if image, _, _ := unstructured.NestedString(rev.Object, "spec", "image"); sameRef(image, ref) {
+			return rev.GetName(), nil
+		}
+	}
 
-	// Load Crossplane chart
-	chartPath, err := client.ChartPathOptions.LocateChart("crossplane", settings)
+	return "", nil
+}
+
+// waitForRevision waits until the revision of a package built from ref
+// exists and, if active is set, is active and healthy. Crossplane may record
+// a rewritten image on the revision, e.g. through an ImageConfig, so with
+// active set any other active revision than previous, the package's current
+// revision before the change, also counts.
+func (m *manager) waitForRevision(ctx context.Context, kind config.PackageKind, name string, ref string, previous string, active bool) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, ProviderTimeout)
+	defer cancel()
+
+	err := m.waiter(packageRevisionGVR(kind), "").WaitForMatch(timeoutCtx, func(rev *unstructured.Unstructured) bool {
+		if rev.GetLabels()[PackageLabel] != name {
+			return false
+		}
+		image, _, _ := unstructured.NestedString(rev.Object, "spec", "image")
+		state, _, _ := unstructured.NestedString(rev.Object, "spec", "desiredState")
+		activeAndHealthy := state == "Active" && conditionTrue(rev, "Healthy")
+		if sameRef(image, ref) {
+			return !active || activeAndHealthy
+		}
+		return active && previous != "" && rev.GetName() != previous && activeAndHealthy
+	})
 	if err != nil {
-		return fmt.Errorf("failed to locate Crossplane chart: %v", err)
+		if timeoutCtx.Err() != nil {
+			return fmt.Errorf("timeout waiting for %s %s revision %s to become active and healthy", strings.ToLower(string(kind)), name, ref)
+		}
+		return fmt.Errorf("failed waiting for %s %s revision %s: %v", strings.ToLower(string(kind)), name, ref, err)
 	}
 
-	chart, err := loader.Load(chartPath)
+	return nil
+}
+
+// sameRef reports whether two package references name the same image once
+// the default registry and tag are filled in
+func sameRef(a, b string) bool {
+	if a == b {
+		return true
+	}
+	refA, err := name.ParseReference(a, name.WithDefaultRegistry(DefaultPackageRegistry))
 	if err != nil {
-		return fmt.Errorf("failed to load Crossplane chart: %v", err)
+		return false
 	}
+	refB, err := name.ParseReference(b, name.WithDefaultRegistry(DefaultPackageRegistry))
+	if err != nil {
+		return false
+	}
+	return refA.Name() == refB.Name()
+}
+
+// WaitForHealth waits for a provider to become healthy
+func (m *manager) WaitForHealth(ctx context.Context, name string) error {
+	return m.WaitForPackageHealth(ctx, config.ProviderKind, name)
+}
+
+// WaitForPackageHealth waits for a Crossplane package of the given kind to become healthy
+func (m *manager) WaitForPackageHealth(ctx context.Context, kind config.PackageKind, name string) error {
+	kindName := strings.ToLower(string(kind))
 
-	// Install Crossplane
-	_, err = client.Run(chart, nil)
+	timeoutCtx, cancel := context.WithTimeout(ctx, ProviderTimeout)
+	defer cancel()
+
+	err := m.waiter(packageGVR(kind), "").WaitForCondition(timeoutCtx, "Healthy", printTransition, name)
 	if err != nil {
-		return fmt.Errorf("failed to install Crossplane: %v", err)
+		if timeoutCtx.Err() != nil {
+			return fmt.Errorf("timeout waiting for %s %s to become healthy", kindName, name)
+		}
+		return fmt.Errorf("failed waiting for %s %s: %v", kindName, name, err)
 	}
 
 	return nil
 }
 
-// WaitForCrossplaneHealth waits for Crossplane to become healthy
-func (m *manager) WaitForCrossplaneHealth(ctx context.Context) error {
-	deployGVR := schema.GroupVersionResource{
-		Group:    "apps",
-		Version:  "v1",
-		Resource: "deployments",
+// List returns a list of installed Crossplane providers
+func (m *manager) List(ctx context.Context) ([]config.Provider, error) {
+	return m.ListPackages(ctx, config.ProviderKind)
+}
+
+// ListPackages returns the installed Crossplane packages of the given kind,
+// with package and version parsed from spec.package
+func (m *manager) ListPackages(ctx context.Context, kind config.PackageKind) ([]config.Provider, error) {
+	list, err := m.Client.Resource(packageGVR(kind)).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %ss: %v", strings.ToLower(string(kind)), err)
+	}
+
+	var packages []config.Provider
+	for _, item := range list.Items {
+		ref, found, err := unstructured.NestedString(item.Object, "spec", "package")
+		if err != nil || !found {
+			continue
+		}
+
+		pkg, version := parsePackageRef(ref)
+		packages = append(packages, config.Provider{
+			Name:    item.GetName(),
+			Package: pkg,
+			Version: version,
+		})
 	}
 
+	return packages, nil
+}
+
+// WaitForCrossplaneHealth waits for Crossplane to become healthy
+func (m *manager) WaitForCrossplaneHealth(ctx context.Context) error {
 	timeoutCtx, cancel := context.WithTimeout(ctx, ProviderTimeout)
 	defer cancel()
 
-	for {
-		select {
-		case <-timeoutCtx.Done():
+	err := m.waiter(deploymentGVR, m.namespace).WaitForCondition(timeoutCtx, "Available", printTransition, CrossplaneDeployment)
+	if err != nil {
+		if timeoutCtx.Err() != nil {
 			return fmt.Errorf("timeout waiting for Crossplane to become healthy")
-		default:
-			deploy, err := m.Client.Resource(deployGVR).Namespace(CrossplaneNamespace).
-				Get(ctx, "crossplane", metav1.GetOptions{})
-			if err != nil {
-				time.Sleep(5 * time.Second)
-				continue
-			}
-
-			conditions, found, err := unstructured.NestedSlice(deploy.Object, "status", "conditions")
-			if err != nil || !found {
-				time.Sleep(5 * time.Second)
-				continue
-			}
-
-			for _, c := range conditions {
-				condition, ok := c.(map[string]interface{})
-				if !ok {
-					continue
-				}
-
-				if condition["type"] == "Available" && condition["status"] == "True" {
-					return nil
-				}
-			}
-
-			time.Sleep(5 * time.Second)
 		}
+		return fmt.Errorf("failed waiting for Crossplane: %v", err)
 	}
+
+	return nil
+}
+
+// waiter returns the shared waiter for a resource in a namespace, creating it
+// on first use
+func (m *manager) waiter(gvr schema.GroupVersionResource, namespace string) *Waiter {
+	m.waitersMu.Lock()
+	defer m.waitersMu.Unlock()
+
+	if m.waiters == nil {
+		m.waiters = map[waiterKey]*Waiter{}
+	}
+
+	key := waiterKey{gvr: gvr, namespace: namespace}
+	w, ok := m.waiters[key]
+	if !ok {
+		w = NewWaiter(m.Client, gvr, namespace)
+		m.waiters[key] = w
+	}
+
+	return w
+}
+
+// Close stops every shared waiter
+func (m *manager) Close() {
+	m.waitersMu.Lock()
+	defer m.waitersMu.Unlock()
+
+	for key, w := range m.waiters {
+		w.Stop()
+		delete(m.waiters, key)
+	}
+}
+
+// printTransition prints a condition transition observed while waiting
+func printTransition(t ConditionTransition) {
+	fmt.Printf("  %s\n", t)
+}
+
+// conditionTrue checks whether an object reports a status condition of the
+// given type with status True
+func conditionTrue(obj *unstructured.Unstructured, conditionType string) bool {
+	conditions, found, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil || !found {
+		return false
+	}
+
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if condition["type"] == conditionType && condition["status"] == "True" {
+			return true
+		}
+	}
+
+	return false
 }
 
 func isAlreadyExists(err error) bool {
-	return err != nil && err.Error() == "already exists"
+	return apierrors.IsAlreadyExists(err) || err != nil && err.Error() == "already exists"
 }
@@ -7,8 +7,48 @@ import (
 
 	"github.com/kanzifucius/crosslab/pkg/config"
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 )
 
+// newFakeClient returns a fake dynamic client that knows the Crossplane package kinds
+func newFakeClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	listKinds := map[schema.GroupVersionResource]string{}
+	for _, kind := range []config.PackageKind{config.ProviderKind, config.FunctionKind, config.ConfigurationKind} {
+		listKinds[packageGVR(kind)] = string(kind) + "List"
+		listKinds[packageRevisionGVR(kind)] = string(kind) + "RevisionList"
+	}
+	listKinds[crdGVR] = "CustomResourceDefinitionList"
+
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objects...)
+}
+
+// newPackageObject builds an unstructured Crossplane package object
+func newPackageObject(kind, name string, spec map[string]interface{}, labels map[string]interface{}, conditions ...string) *unstructured.Unstructured {
+	var conds []interface{}
+	for _, c := range conditions {
+		conds = append(conds, map[string]interface{}{"type": c, "status": "True"})
+	}
+
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "pkg.crossplane.io/v1",
+			"kind":       kind,
+			"metadata": map[string]interface{}{
+				"name":   name,
+				"labels": labels,
+			},
+			"spec": spec,
+			"status": map[string]interface{}{
+				"conditions": conds,
+			},
+		},
+	}
+}
+
 func TestExists(t *testing.T) {
 	// Test case: provider exists
 	t.Run("provider exists", func(t *testing.T) {
@@ -101,7 +141,7 @@ func TestInstall(t *testing.T) {
 
 	// Test case: provider already exists and force is true
 	t.Run("provider exists and force is true", func(t *testing.T) {
-		var deleteCalled, installCalled bool
+		var deleteCalled, upgradeCalled bool
 		mockManager := &mockManager{
 			ExistsFunc: func(ctx context.Context, name string) (bool, error) {
 				return true, nil
@@ -110,8 +150,8 @@ func TestInstall(t *testing.T) {
 				deleteCalled = true
 				return nil
 			},
-			InstallFunc: func(ctx context.Context, p config.Provider, force bool) error {
-				installCalled = true
+			UpgradeFunc: func(ctx context.Context, kind config.PackageKind, p config.Provider) error {
+				upgradeCalled = true
 				return nil
 			},
 		}
@@ -122,17 +162,18 @@ func TestInstall(t *testing.T) {
 			Version: "v0.24.1",
 		}
 
-		// Manually simulate what the real implementation would do
+		// Manually simulate what the real implementation would do: an
+		// existing provider is upgraded in place instead of deleted
 		ctx := context.Background()
 		exists, _ := mockManager.Exists(ctx, provider.Name)
+		var err error
 		if exists && true { // force is true
-			_ = mockManager.Delete(ctx, provider.Name)
+			err = mockManager.Upgrade(ctx, config.ProviderKind, provider)
 		}
-		err := mockManager.Install(ctx, provider, true)
 
 		assert.NoError(t, err)
-		assert.True(t, deleteCalled)
-		assert.True(t, installCalled)
+		assert.False(t, deleteCalled)
+		assert.True(t, upgradeCalled)
 	})
 
 	// Test case: error during installation
@@ -260,12 +301,12 @@ func TestInstallCrossplane(t *testing.T) {
 	// Test case: successful installation
 	t.Run("successful installation", func(t *testing.T) {
 		mockManager := &mockManager{
-			InstallCrossplaneFunc: func(ctx context.Context) error {
+			InstallCrossplaneFunc: func(ctx context.Context, cfg config.CrossplaneConfig) error {
 				return nil
 			},
 		}
 
-		err := mockManager.InstallCrossplane(context.Background())
+		err := mockManager.InstallCrossplane(context.Background(), config.CrossplaneConfig{})
 		assert.NoError(t, err)
 	})
 
@@ -273,12 +314,12 @@ func TestInstallCrossplane(t *testing.T) {
 	t.Run("error during installation", func(t *testing.T) {
 		expectedErr := errors.New("installation error")
 		mockManager := &mockManager{
-			InstallCrossplaneFunc: func(ctx context.Context) error {
+			InstallCrossplaneFunc: func(ctx context.Context, cfg config.CrossplaneConfig) error {
 				return expectedErr
 			},
 		}
 
-		err := mockManager.InstallCrossplane(context.Background())
+		err := mockManager.InstallCrossplane(context.Background(), config.CrossplaneConfig{})
 		assert.Error(t, err)
 		assert.Equal(t, expectedErr, err)
 	})
@@ -311,3 +352,236 @@ func TestWaitForCrossplaneHealth(t *testing.T) {
 		assert.Equal(t, expectedErr, err)
 	})
 }
+
+func TestPackageGVR(t *testing.T) {
+	tests := []struct {
+		kind     config.PackageKind
+		resource string
+	}{
+		{kind: config.ProviderKind, resource: "providers"},
+		{kind: config.FunctionKind, resource: "functions"},
+		{kind: config.ConfigurationKind, resource: "configurations"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			gvr := packageGVR(tt.kind)
+			assert.Equal(t, "pkg.crossplane.io", gvr.Group)
+			assert.Equal(t, "v1", gvr.Version)
+			assert.Equal(t, tt.resource, gvr.Resource)
+		})
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	provider := newPackageObject("Provider", "provider-aws-s3",
+		map[string]interface{}{"package": "xpkg.upbound.io/upbound/provider-aws-s3:v1.0.0"}, nil, "Installed", "Healthy")
+	revision := newPackageObject("ProviderRevision", "provider-aws-s3-abc123",
+		map[string]interface{}{"image": "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0", "desiredState": "Active"},
+		map[string]interface{}{PackageLabel: "provider-aws-s3"}, "Healthy")
+
+	client := newFakeClient(provider, revision)
+	m := &manager{Client: client}
+	ctx := context.Background()
+
+	err := m.Upgrade(ctx, config.ProviderKind, config.Provider{
+		Name:    "provider-aws-s3",
+		Package: "xpkg.upbound.io/upbound/provider-aws-s3",
+		Version: "v1.1.0",
+	})
+	assert.NoError(t, err)
+
+	installed, err := m.GetPackage(ctx, config.ProviderKind, "provider-aws-s3")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.1.0", installed.Version)
+
+	missing, err := m.GetPackage(ctx, config.ProviderKind, "provider-aws-ec2")
+	assert.NoError(t, err)
+	assert.Nil(t, missing)
+
+	// The provider is patched in place rather than deleted
+	_, err = client.Resource(packageGVR(config.ProviderKind)).Get(ctx, "provider-aws-s3", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+func TestPackageSpec(t *testing.T) {
+	limit := int64(3)
+	spec := packageSpec(config.Provider{
+		Name:                     "provider-internal",
+		Package:                  "registry.example.com/crossplane/provider-internal",
+		Version:                  "v1.0.0",
+		PackagePullPolicy:        "Always",
+		PackagePullSecrets:       []string{"registry-example-com"},
+		RevisionActivationPolicy: config.ManualActivation,
+		RevisionHistoryLimit:     &limit,
+		SkipDependencyResolution: true,
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"package":                  "registry.example.com/crossplane/provider-internal:v1.0.0",
+		"packagePullPolicy":        "Always",
+		"packagePullSecrets":       []interface{}{map[string]interface{}{"name": "registry-example-com"}},
+		"revisionActivationPolicy": "Manual",
+		"revisionHistoryLimit":     int64(3),
+		"skipDependencyResolution": true,
+	}, spec)
+}
+
+func TestUpgradeManualActivation(t *testing.T) {
+	provider := newPackageObject("Provider", "provider-aws-s3",
+		map[string]interface{}{"package": "xpkg.upbound.io/upbound/provider-aws-s3:v1.0.0"}, nil, "Installed", "Healthy")
+	revision := newPackageObject("ProviderRevision", "provider-aws-s3-def456",
+		map[string]interface{}{"image": "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0", "desiredState": "Inactive"},
+		map[string]interface{}{PackageLabel: "provider-aws-s3"})
+
+	m := &manager{Client: newFakeClient(provider, revision)}
+	err := m.Upgrade(context.Background(), config.ProviderKind, config.Provider{
+		Name:                     "provider-aws-s3",
+		Package:                  "xpkg.upbound.io/upbound/provider-aws-s3",
+		Version:                  "v1.1.0",
+		RevisionActivationPolicy: config.ManualActivation,
+	})
+	assert.NoError(t, err)
+
+	name, err := m.Revision(context.Background(), config.ProviderKind, config.Provider{
+		Name:    "provider-aws-s3",
+		Package: "xpkg.upbound.io/upbound/provider-aws-s3",
+		Version: "v1.1.0",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "provider-aws-s3-def456", name)
+}
+
+func TestUpgradeSpec(t *testing.T) {
+	spec := upgradeSpec(config.Provider{Name: "provider-helm", Package: "xpkg.upbound.io/upbound/provider-helm", Version: "v0.20.4"})
+
+	assert.Equal(t, map[string]interface{}{
+		"package":                  "xpkg.upbound.io/upbound/provider-helm:v0.20.4",
+		"packagePullPolicy":        nil,
+		"packagePullSecrets":       nil,
+		"revisionActivationPolicy": nil,
+		"revisionHistoryLimit":     nil,
+		"runtimeConfigRef":         nil,
+		"skipDependencyResolution": false,
+	}, spec)
+}
+
+func TestDriftedOptions(t *testing.T) {
+	limit := int64(3)
+	pkg := config.Provider{
+		Name:                 "provider-helm",
+		Package:              "xpkg.upbound.io/upbound/provider-helm",
+		Version:              "v0.20.4",
+		PackagePullSecrets:   []string{"registry-creds"},
+		RevisionHistoryLimit: &limit,
+	}
+	m := &manager{Client: newFakeClient(newPackageObject("Provider", pkg.Name, packageSpec(pkg), nil))}
+	ctx := context.Background()
+
+	drifted, err := m.Drifted(ctx, config.ProviderKind, pkg)
+	assert.NoError(t, err)
+	assert.False(t, drifted)
+
+	// Options set to Crossplane's defaults match unset ones
+	defaults := pkg
+	defaults.PackagePullPolicy = "IfNotPresent"
+	defaults.RevisionActivationPolicy = config.AutomaticActivation
+	drifted, err = m.Drifted(ctx, config.ProviderKind, defaults)
+	assert.NoError(t, err)
+	assert.False(t, drifted)
+
+	for name, change := range map[string]func(p *config.Provider){
+		"pull policy":       func(p *config.Provider) { p.PackagePullPolicy = "Always" },
+		"pull secrets":      func(p *config.Provider) { p.PackagePullSecrets = nil },
+		"activation policy": func(p *config.Provider) { p.RevisionActivationPolicy = config.ManualActivation },
+		"history limit":     func(p *config.Provider) { p.RevisionHistoryLimit = nil },
+		"skip dependencies": func(p *config.Provider) { p.SkipDependencyResolution = true },
+	} {
+		changed := pkg
+		change(&changed)
+		drifted, err := m.Drifted(ctx, config.ProviderKind, changed)
+		assert.NoError(t, err)
+		assert.True(t, drifted, name)
+	}
+}
+
+func TestWaiterPerNamespace(t *testing.T) {
+	m := &manager{Client: newFakeClient()}
+
+	system := m.waiter(deploymentGVR, CrossplaneNamespace)
+	assert.Same(t, system, m.waiter(deploymentGVR, CrossplaneNamespace))
+	other := m.waiter(deploymentGVR, "default")
+	assert.NotSame(t, system, other)
+	assert.Equal(t, "default", other.namespace)
+
+	m.Close()
+	for _, w := range []*Waiter{system, other} {
+		select {
+		case <-w.stopCh:
+		default:
+			t.Errorf("waiter for namespace %s was not stopped", w.namespace)
+		}
+	}
+	assert.NotSame(t, system, m.waiter(deploymentGVR, CrossplaneNamespace))
+}
+
+func TestLocalRegistryRef(t *testing.T) {
+	pkg := config.Provider{Name: "platform-ref", Package: "localhost:5001/platform-ref", Version: "v0.1.0"}
+	spec := packageSpec(pkg)
+	assert.Equal(t, "kind-registry.kube-public.svc.cluster.local:5000/platform-ref:v0.1.0", spec["package"])
+
+	configuration := newPackageObject("Configuration", "platform-ref", spec, nil, "Installed", "Healthy")
+	m := &manager{Client: newFakeClient(configuration)}
+	installed, err := m.GetPackage(context.Background(), config.ConfigurationKind, "platform-ref")
+	assert.NoError(t, err)
+	assert.Equal(t, pkg.Package, installed.Package)
+	assert.Equal(t, pkg.Version, installed.Version)
+}
+
+func TestUpgradeRewrittenImage(t *testing.T) {
+	provider := newPackageObject("Provider", "provider-aws-s3",
+		map[string]interface{}{"package": "xpkg.upbound.io/upbound/provider-aws-s3:v1.0.0"}, nil, "Installed", "Healthy")
+	_ = unstructured.SetNestedField(provider.Object, "provider-aws-s3-0a1b2c3d4e5f", "status", "currentRevision")
+	previous := newPackageObject("ProviderRevision", "provider-aws-s3-0a1b2c3d4e5f",
+		map[string]interface{}{"image": "xpkg.upbound.io/upbound/provider-aws-s3:v1.0.0", "desiredState": "Inactive"},
+		map[string]interface{}{PackageLabel: "provider-aws-s3"}, "Healthy")
+	// An ImageConfig made Crossplane pull the new revision from a mirror
+	revision := newPackageObject("ProviderRevision", "provider-aws-s3-abc123",
+		map[string]interface{}{"image": "mirror.example.com/upbound/provider-aws-s3:v1.1.0", "desiredState": "Active"},
+		map[string]interface{}{PackageLabel: "provider-aws-s3"}, "Healthy")
+
+	m := &manager{Client: newFakeClient(provider, previous, revision)}
+	err := m.Upgrade(context.Background(), config.ProviderKind, config.Provider{
+		Name:    "provider-aws-s3",
+		Package: "xpkg.upbound.io/upbound/provider-aws-s3",
+		Version: "v1.1.0",
+	})
+	assert.NoError(t, err)
+}
+
+func TestInstallPackageManualActivation(t *testing.T) {
+	pkg := config.Provider{
+		Name:                     "provider-aws-s3",
+		Package:                  "xpkg.upbound.io/upbound/provider-aws-s3",
+		Version:                  "v1.1.0",
+		RevisionActivationPolicy: config.ManualActivation,
+	}
+	// The first revision of a Manual package is created inactive
+	revision := newPackageObject("ProviderRevision", "provider-aws-s3-0a1b2c3d4e5f",
+		map[string]interface{}{"image": pkg.Ref(), "desiredState": "Inactive"},
+		map[string]interface{}{PackageLabel: pkg.Name})
+
+	m := &manager{Client: newFakeClient(revision)}
+	ctx := context.Background()
+	assert.NoError(t, m.InstallPackage(ctx, config.ProviderKind, pkg, false))
+
+	name, err := m.Revision(ctx, config.ProviderKind, pkg)
+	assert.NoError(t, err)
+	assert.Equal(t, "provider-aws-s3-0a1b2c3d4e5f", name)
+}
+
+func TestSameRef(t *testing.T) {
+	assert.True(t, sameRef("upbound/provider-helm:v0.20.4", "xpkg.upbound.io/upbound/provider-helm:v0.20.4"))
+	assert.True(t, sameRef("xpkg.upbound.io/upbound/provider-helm", "xpkg.upbound.io/upbound/provider-helm:latest"))
+	assert.False(t, sameRef("xpkg.upbound.io/upbound/provider-helm:v0.20.4", "xpkg.upbound.io/upbound/provider-helm:v0.21.0"))
+}
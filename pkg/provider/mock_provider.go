@@ -8,13 +8,32 @@ import (
 
 // mockManager implements provider operations for testing
 type mockManager struct {
-	InstallCrossplaneFunc func(ctx context.Context) error
-	WaitForCrossplaneFunc func(ctx context.Context) error
-	InstallFunc           func(ctx context.Context, p config.Provider, force bool) error
-	WaitForHealthFunc     func(ctx context.Context, name string) error
-	ListFunc              func(ctx context.Context) ([]config.Provider, error)
-	DeleteFunc            func(ctx context.Context, name string) error
-	ExistsFunc            func(ctx context.Context, name string) (bool, error)
+	InstallCrossplaneFunc   func(ctx context.Context, cfg config.CrossplaneConfig) error
+	UpgradeCrossplaneFunc   func(ctx context.Context, cfg config.CrossplaneConfig) error
+	UninstallCrossplaneFunc func(ctx context.Context, opts UninstallOptions) error
+	WaitForCrossplaneFunc   func(ctx context.Context) error
+	CrossplaneStatusFunc    func(ctx context.Context) (*CrossplaneStatus, error)
+	ServerVersionFunc       func(ctx context.Context) (string, error)
+	InstallFunc             func(ctx context.Context, p config.Provider, force bool) error
+	InstallPackageFunc      func(ctx context.Context, kind config.PackageKind, p config.Provider, force bool) error
+	UpgradeFunc             func(ctx context.Context, kind config.PackageKind, p config.Provider) error
+	RevisionFunc            func(ctx context.Context, kind config.PackageKind, p config.Provider) (string, error)
+	DriftedFunc             func(ctx context.Context, kind config.PackageKind, p config.Provider) (bool, error)
+	GetPackageFunc          func(ctx context.Context, kind config.PackageKind, name string) (*config.Provider, error)
+	WaitForHealthFunc       func(ctx context.Context, name string) error
+	WaitForPackageFunc      func(ctx context.Context, kind config.PackageKind, name string) error
+	ListFunc                func(ctx context.Context) ([]config.Provider, error)
+	ListPackagesFunc        func(ctx context.Context, kind config.PackageKind) ([]config.Provider, error)
+	StatusFunc              func(ctx context.Context, kind config.PackageKind, name string) (*PackageStatus, error)
+	ListStatusFunc          func(ctx context.Context, kind config.PackageKind) ([]PackageStatus, error)
+	DeleteFunc              func(ctx context.Context, name string) error
+	DeletePackageFunc       func(ctx context.Context, kind config.PackageKind, name string) error
+	ExistsFunc              func(ctx context.Context, name string) (bool, error)
+	ApplyProviderConfigFunc func(ctx context.Context, pc config.ProviderConfig) error
+	DeployLocalStackFunc    func(ctx context.Context, cfg config.LocalStackConfig) (string, error)
+	ApplyRegistrySecretFunc func(ctx context.Context, auth config.RegistryAuth) error
+	DependenciesFunc        func(ctx context.Context) ([]string, error)
+	CloseFunc               func()
 }
 
 // NewMockManager creates a new mock provider manager
@@ -22,9 +41,23 @@ func NewMockManager() Manager {
 	return &mockManager{}
 }
 
-func (m *mockManager) InstallCrossplane(ctx context.Context) error {
+func (m *mockManager) InstallCrossplane(ctx context.Context, cfg config.CrossplaneConfig) error {
 	if m.InstallCrossplaneFunc != nil {
-		return m.InstallCrossplaneFunc(ctx)
+		return m.InstallCrossplaneFunc(ctx, cfg)
+	}
+	return nil
+}
+
+func (m *mockManager) UpgradeCrossplane(ctx context.Context, cfg config.CrossplaneConfig) error {
+	if m.UpgradeCrossplaneFunc != nil {
+		return m.UpgradeCrossplaneFunc(ctx, cfg)
+	}
+	return nil
+}
+
+func (m *mockManager) UninstallCrossplane(ctx context.Context, opts UninstallOptions) error {
+	if m.UninstallCrossplaneFunc != nil {
+		return m.UninstallCrossplaneFunc(ctx, opts)
 	}
 	return nil
 }
@@ -36,6 +69,20 @@ func (m *mockManager) WaitForCrossplaneHealth(ctx context.Context) error {
 	return nil
 }
 
+func (m *mockManager) CrossplaneStatus(ctx context.Context) (*CrossplaneStatus, error) {
+	if m.CrossplaneStatusFunc != nil {
+		return m.CrossplaneStatusFunc(ctx)
+	}
+	return &CrossplaneStatus{}, nil
+}
+
+func (m *mockManager) ServerVersion(ctx context.Context) (string, error) {
+	if m.ServerVersionFunc != nil {
+		return m.ServerVersionFunc(ctx)
+	}
+	return "", nil
+}
+
 func (m *mockManager) Install(ctx context.Context, p config.Provider, force bool) error {
 	if m.InstallFunc != nil {
 		return m.InstallFunc(ctx, p, force)
@@ -43,6 +90,41 @@ func (m *mockManager) Install(ctx context.Context, p config.Provider, force bool
 	return nil
 }
 
+func (m *mockManager) InstallPackage(ctx context.Context, kind config.PackageKind, p config.Provider, force bool) error {
+	if m.InstallPackageFunc != nil {
+		return m.InstallPackageFunc(ctx, kind, p, force)
+	}
+	return nil
+}
+
+func (m *mockManager) Upgrade(ctx context.Context, kind config.PackageKind, p config.Provider) error {
+	if m.UpgradeFunc != nil {
+		return m.UpgradeFunc(ctx, kind, p)
+	}
+	return nil
+}
+
+func (m *mockManager) Revision(ctx context.Context, kind config.PackageKind, p config.Provider) (string, error) {
+	if m.RevisionFunc != nil {
+		return m.RevisionFunc(ctx, kind, p)
+	}
+	return "", nil
+}
+
+func (m *mockManager) Drifted(ctx context.Context, kind config.PackageKind, p config.Provider) (bool, error) {
+	if m.DriftedFunc != nil {
+		return m.DriftedFunc(ctx, kind, p)
+	}
+	return false, nil
+}
+
+func (m *mockManager) GetPackage(ctx context.Context, kind config.PackageKind, name string) (*config.Provider, error) {
+	if m.GetPackageFunc != nil {
+		return m.GetPackageFunc(ctx, kind, name)
+	}
+	return nil, nil
+}
+
 func (m *mockManager) WaitForHealth(ctx context.Context, name string) error {
 	if m.WaitForHealthFunc != nil {
 		return m.WaitForHealthFunc(ctx, name)
@@ -50,6 +132,13 @@ func (m *mockManager) WaitForHealth(ctx context.Context, name string) error {
 	return nil
 }
 
+func (m *mockManager) WaitForPackageHealth(ctx context.Context, kind config.PackageKind, name string) error {
+	if m.WaitForPackageFunc != nil {
+		return m.WaitForPackageFunc(ctx, kind, name)
+	}
+	return nil
+}
+
 func (m *mockManager) List(ctx context.Context) ([]config.Provider, error) {
 	if m.ListFunc != nil {
 		return m.ListFunc(ctx)
@@ -57,6 +146,27 @@ func (m *mockManager) List(ctx context.Context) ([]config.Provider, error) {
 	return nil, nil
 }
 
+func (m *mockManager) ListPackages(ctx context.Context, kind config.PackageKind) ([]config.Provider, error) {
+	if m.ListPackagesFunc != nil {
+		return m.ListPackagesFunc(ctx, kind)
+	}
+	return nil, nil
+}
+
+func (m *mockManager) Status(ctx context.Context, kind config.PackageKind, name string) (*PackageStatus, error) {
+	if m.StatusFunc != nil {
+		return m.StatusFunc(ctx, kind, name)
+	}
+	return nil, nil
+}
+
+func (m *mockManager) ListStatus(ctx context.Context, kind config.PackageKind) ([]PackageStatus, error) {
+	if m.ListStatusFunc != nil {
+		return m.ListStatusFunc(ctx, kind)
+	}
+	return nil, nil
+}
+
 func (m *mockManager) Delete(ctx context.Context, name string) error {
 	if m.DeleteFunc != nil {
 		return m.DeleteFunc(ctx, name)
@@ -64,9 +174,50 @@ func (m *mockManager) Delete(ctx context.Context, name string) error {
 	return nil
 }
 
+func (m *mockManager) DeletePackage(ctx context.Context, kind config.PackageKind, name string) error {
+	if m.DeletePackageFunc != nil {
+		return m.DeletePackageFunc(ctx, kind, name)
+	}
+	return nil
+}
+
 func (m *mockManager) Exists(ctx context.Context, name string) (bool, error) {
 	if m.ExistsFunc != nil {
 		return m.ExistsFunc(ctx, name)
 	}
 	return false, nil
 }
+
+func (m *mockManager) ApplyProviderConfig(ctx context.Context, pc config.ProviderConfig) error {
+	if m.ApplyProviderConfigFunc != nil {
+		return m.ApplyProviderConfigFunc(ctx, pc)
+	}
+	return nil
+}
+
+func (m *mockManager) DeployLocalStack(ctx context.Context, cfg config.LocalStackConfig) (string, error) {
+	if m.DeployLocalStackFunc != nil {
+		return m.DeployLocalStackFunc(ctx, cfg)
+	}
+	return "", nil
+}
+
+func (m *mockManager) ApplyRegistrySecret(ctx context.Context, auth config.RegistryAuth) error {
+	if m.ApplyRegistrySecretFunc != nil {
+		return m.ApplyRegistrySecretFunc(ctx, auth)
+	}
+	return nil
+}
+
+func (m *mockManager) Dependencies(ctx context.Context) ([]string, error) {
+	if m.DependenciesFunc != nil {
+		return m.DependenciesFunc(ctx)
+	}
+	return nil, nil
+}
+
+func (m *mockManager) Close() {
+	if m.CloseFunc != nil {
+		m.CloseFunc()
+	}
+}
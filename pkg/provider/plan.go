@@ -0,0 +1,228 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+)
+
+// Action is the operation a plan performs on a package
+type Action string
+
+const (
+	// ActionInstall installs a package that is missing from the cluster
+	ActionInstall Action = "install"
+	// ActionUpgrade upgrades a package whose installed version or settings differ from the configuration
+	ActionUpgrade Action = "upgrade"
+	// ActionPrune deletes a package that is not in the configuration
+	ActionPrune Action = "prune"
+)
+
+// Change is a single step of a plan
+type Change struct {
+	Action  Action             `json:"action" yaml:"action"`
+	Kind    config.PackageKind `json:"kind" yaml:"kind"`
+	Name    string             `json:"name" yaml:"name"`
+	Desired *config.Provider   `json:"desired,omitempty" yaml:"desired,omitempty"`
+	Current *config.Provider   `json:"current,omitempty" yaml:"current,omitempty"`
+}
+
+// Plan is the ordered list of changes that reconcile a cluster with the configuration
+type Plan struct {
+	Changes []Change `json:"changes" yaml:"changes"`
+}
+
+// Empty reports whether the plan has no changes
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// ComputePlan compares the desired packages with the installed ones. Missing
+// packages are installed and version mismatches upgraded, in the order of
+// desired. When prune is set, installed packages that are not desired are
+// deleted after all other changes, configurations first and providers last.
+func ComputePlan(desired []config.Package, installed []config.Package, prune bool) *Plan {
+	return computePlan(desired, installed, prune, nil)
+}
+
+// computePlan is ComputePlan that also upgrades the installed packages whose
+// settings drifted from the configuration, keyed by kind and name
+func computePlan(desired []config.Package, installed []config.Package, prune bool, drifted map[config.PackageKind]map[string]bool) *Plan {
+	current := map[config.PackageKind]map[string]config.Provider{}
+	for _, p := range installed {
+		if current[p.Kind] == nil {
+			current[p.Kind] = map[string]config.Provider{}
+		}
+		current[p.Kind][p.Name] = p.Provider
+	}
+
+	plan := &Plan{}
+	wanted := map[config.PackageKind]map[string]bool{}
+	for _, p := range desired {
+		if wanted[p.Kind] == nil {
+			wanted[p.Kind] = map[string]bool{}
+		}
+		wanted[p.Kind][p.Name] = true
+
+		desiredPkg := p.Provider
+		currentPkg, ok := current[p.Kind][p.Name]
+		switch {
+		case !ok:
+			plan.Changes = append(plan.Changes, Change{Action: ActionInstall, Kind: p.Kind, Name: p.Name, Desired: &desiredPkg})
+		case currentPkg.Ref() != desiredPkg.Ref() || drifted[p.Kind][p.Name]:
+			plan.Changes = append(plan.Changes, Change{Action: ActionUpgrade, Kind: p.Kind, Name: p.Name, Desired: &desiredPkg, Current: &currentPkg})
+		}
+	}
+
+	if !prune {
+		return plan
+	}
+
+	for i := len(config.PackageKinds) - 1; i >= 0; i-- {
+		kind := config.PackageKinds[i]
+		for _, p := range installed {
+			if p.Kind != kind || wanted[kind][p.Name] {
+				continue
+			}
+
+			currentPkg := p.Provider
+			plan.Changes = append(plan.Changes, Change{Action: ActionPrune, Kind: kind, Name: p.Name, Current: &currentPkg})
+		}
+	}
+
+	return plan
+}
+
+// ListInstalled returns every Crossplane package installed in the cluster
+func ListInstalled(ctx context.Context, m Manager) ([]config.Package, error) {
+	var installed []config.Package
+	for _, kind := range config.PackageKinds {
+		packages, err := m.ListPackages(ctx, kind)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range packages {
+			installed = append(installed, config.Package{Kind: kind, Provider: p})
+		}
+	}
+
+	return installed, nil
+}
+
+// BuildPlan computes the plan that reconciles the cluster with cfg. Packages
+// installed at the configured version are upgraded when their options or
+// runtime configuration drifted. Packages Crossplane installed as
+// dependencies of other packages are not pruned, as Crossplane would install
+// them again.
+func BuildPlan(ctx context.Context, m Manager, cfg *config.Config, prune bool) (*Plan, error) {
+	installed, err := ListInstalled(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := map[config.PackageKind]map[string]string{}
+	for _, p := range installed {
+		if refs[p.Kind] == nil {
+			refs[p.Kind] = map[string]string{}
+		}
+		refs[p.Kind][p.Name] = p.Ref()
+	}
+
+	desired := cfg.Packages()
+	drifted := map[config.PackageKind]map[string]bool{}
+	for _, p := range desired {
+		if ref, ok := refs[p.Kind][p.Name]; !ok || ref != p.Ref() {
+			continue
+		}
+		changed, err := m.Drifted(ctx, p.Kind, p.Provider)
+		if err != nil {
+			return nil, err
+		}
+		if drifted[p.Kind] == nil {
+			drifted[p.Kind] = map[string]bool{}
+		}
+		drifted[p.Kind][p.Name] = changed
+	}
+
+	plan := computePlan(desired, installed, prune, drifted)
+	if !prune {
+		return plan, nil
+	}
+
+	dependencies, err := m.Dependencies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes := plan.Changes[:0]
+	for _, c := range plan.Changes {
+		if c.Action == ActionPrune && isDependency(c.Current.Package, dependencies) {
+			continue
+		}
+		changes = append(changes, c)
+	}
+	plan.Changes = changes
+
+	return plan, nil
+}
+
+// Apply applies the plan changes in order, waiting for installed and
+// upgraded packages to become healthy. New packages with the Manual revision
+// activation policy are not waited for, as their first revision stays
+// inactive until it is activated. Progress is written to out.
+func (p *Plan) Apply(ctx context.Context, m Manager, out io.Writer) error {
+	for _, c := range p.Changes {
+		kindName := strings.ToLower(string(c.Kind))
+
+		switch c.Action {
+		case ActionInstall:
+			fmt.Fprintf(out, "Installing %s %s (%s)...\n", kindName, c.Name, c.Desired.Ref())
+			if err := m.InstallPackage(ctx, c.Kind, *c.Desired, false); err != nil {
+				return fmt.Errorf("failed to install %s %s: %v", kindName, c.Name, err)
+			}
+			if c.Desired.RevisionActivationPolicy == config.ManualActivation {
+				revision, err := m.Revision(ctx, c.Kind, *c.Desired)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(out, "%s %s revision %s is staged; set its desiredState to Active to activate it\n", c.Kind, c.Name, revision)
+			} else if err := m.WaitForPackageHealth(ctx, c.Kind, c.Name); err != nil {
+				return fmt.Errorf("failed while waiting for %s %s: %v", kindName, c.Name, err)
+			}
+		case ActionUpgrade:
+			if c.Current.Ref() == c.Desired.Ref() {
+				fmt.Fprintf(out, "Updating the settings of %s %s (%s)...\n", kindName, c.Name, c.Desired.Ref())
+			} else {
+				fmt.Fprintf(out, "Upgrading %s %s from %s to %s...\n", kindName, c.Name, c.Current.Ref(), c.Desired.Ref())
+			}
+			if err := m.Upgrade(ctx, c.Kind, *c.Desired); err != nil {
+				return fmt.Errorf("failed to upgrade %s %s: %v", kindName, c.Name, err)
+			}
+		case ActionPrune:
+			fmt.Fprintf(out, "Pruning %s %s...\n", kindName, c.Name)
+			if err := m.DeletePackage(ctx, c.Kind, c.Name); err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintf(out, "%s %s %s ✓\n", c.Kind, c.Name, pastTense(c.Action))
+	}
+
+	return nil
+}
+
+// pastTense returns the past tense of an action for progress output
+func pastTense(a Action) string {
+	switch a {
+	case ActionInstall:
+		return "installed"
+	case ActionUpgrade:
+		return "upgraded"
+	case ActionPrune:
+		return "pruned"
+	}
+	return string(a)
+}
@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestComputePlan(t *testing.T) {
+	desired := []config.Package{
+		{Kind: config.ProviderKind, Provider: config.Provider{Name: "provider-aws-s3", Package: "xpkg.upbound.io/upbound/provider-aws-s3", Version: "v1.1.0"}},
+		{Kind: config.ProviderKind, Provider: config.Provider{Name: "provider-aws-iam", Package: "xpkg.upbound.io/upbound/provider-aws-iam", Version: "v1"}},
+		{Kind: config.FunctionKind, Provider: config.Provider{Name: "function-patch-and-transform", Package: "xpkg.upbound.io/crossplane-contrib/function-patch-and-transform", Version: "v0.8.2"}},
+	}
+	installed := []config.Package{
+		{Kind: config.ProviderKind, Provider: config.Provider{Name: "provider-aws-s3", Package: "xpkg.upbound.io/upbound/provider-aws-s3", Version: "v1.0.0"}},
+		{Kind: config.ProviderKind, Provider: config.Provider{Name: "provider-aws-iam", Package: "xpkg.upbound.io/upbound/provider-aws-iam", Version: "v1"}},
+		{Kind: config.ProviderKind, Provider: config.Provider{Name: "provider-helm", Package: "xpkg.upbound.io/upbound/provider-helm", Version: "v0.20.4"}},
+		{Kind: config.ConfigurationKind, Provider: config.Provider{Name: "platform-ref-aws", Package: "xpkg.upbound.io/upbound/platform-ref-aws", Version: "v1.4.0"}},
+	}
+
+	t.Run("without prune", func(t *testing.T) {
+		plan := ComputePlan(desired, installed, false)
+		assert.Len(t, plan.Changes, 2)
+
+		assert.Equal(t, ActionUpgrade, plan.Changes[0].Action)
+		assert.Equal(t, "provider-aws-s3", plan.Changes[0].Name)
+		assert.Equal(t, "v1.0.0", plan.Changes[0].Current.Version)
+		assert.Equal(t, "v1.1.0", plan.Changes[0].Desired.Version)
+
+		assert.Equal(t, ActionInstall, plan.Changes[1].Action)
+		assert.Equal(t, config.FunctionKind, plan.Changes[1].Kind)
+	})
+
+	t.Run("with prune", func(t *testing.T) {
+		plan := ComputePlan(desired, installed, true)
+		assert.Len(t, plan.Changes, 4)
+
+		// Configurations are pruned before the providers they may depend on
+		assert.Equal(t, ActionPrune, plan.Changes[2].Action)
+		assert.Equal(t, "platform-ref-aws", plan.Changes[2].Name)
+		assert.Equal(t, ActionPrune, plan.Changes[3].Action)
+		assert.Equal(t, "provider-helm", plan.Changes[3].Name)
+	})
+
+	t.Run("in sync", func(t *testing.T) {
+		plan := ComputePlan(desired[1:2], installed[1:2], true)
+		assert.True(t, plan.Empty())
+	})
+}
+
+func TestPlanApply(t *testing.T) {
+	var installed, upgraded, pruned []string
+	m := &mockManager{
+		InstallPackageFunc: func(ctx context.Context, kind config.PackageKind, p config.Provider, force bool) error {
+			installed = append(installed, p.Name)
+			return nil
+		},
+		UpgradeFunc: func(ctx context.Context, kind config.PackageKind, p config.Provider) error {
+			upgraded = append(upgraded, p.Name)
+			return nil
+		},
+		DeletePackageFunc: func(ctx context.Context, kind config.PackageKind, name string) error {
+			pruned = append(pruned, name)
+			return nil
+		},
+	}
+
+	desired := &config.Provider{Name: "provider-aws-s3", Package: "xpkg.upbound.io/upbound/provider-aws-s3", Version: "v1.1.0"}
+	current := &config.Provider{Name: "provider-aws-s3", Package: "xpkg.upbound.io/upbound/provider-aws-s3", Version: "v1.0.0"}
+	missing := &config.Provider{Name: "provider-aws-iam", Package: "xpkg.upbound.io/upbound/provider-aws-iam", Version: "v1"}
+	plan := &Plan{Changes: []Change{
+		{Action: ActionInstall, Kind: config.ProviderKind, Name: "provider-aws-iam", Desired: missing},
+		{Action: ActionUpgrade, Kind: config.ProviderKind, Name: "provider-aws-s3", Desired: desired, Current: current},
+		{Action: ActionPrune, Kind: config.ProviderKind, Name: "provider-helm", Current: current},
+	}}
+
+	err := plan.Apply(context.Background(), m, io.Discard)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"provider-aws-iam"}, installed)
+	assert.Equal(t, []string{"provider-aws-s3"}, upgraded)
+	assert.Equal(t, []string{"provider-helm"}, pruned)
+}
+
+func TestPlanApplyManualActivation(t *testing.T) {
+	m := &mockManager{
+		RevisionFunc: func(ctx context.Context, kind config.PackageKind, p config.Provider) (string, error) {
+			return "provider-aws-s3-0a1b2c3d4e5f", nil
+		},
+		WaitForPackageFunc: func(ctx context.Context, kind config.PackageKind, name string) error {
+			t.Errorf("waited for %s to become healthy, but its first revision is inactive", name)
+			return nil
+		},
+	}
+
+	desired := &config.Provider{Name: "provider-aws-s3", Package: "xpkg.upbound.io/upbound/provider-aws-s3", Version: "v1.1.0", RevisionActivationPolicy: config.ManualActivation}
+	plan := &Plan{Changes: []Change{{Action: ActionInstall, Kind: config.ProviderKind, Name: desired.Name, Desired: desired}}}
+
+	var out strings.Builder
+	assert.NoError(t, plan.Apply(context.Background(), m, &out))
+	assert.Contains(t, out.String(), "revision provider-aws-s3-0a1b2c3d4e5f is staged")
+}
+
+func TestBuildPlanKeepsDependencies(t *testing.T) {
+	lock := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "pkg.crossplane.io/v1beta1",
+		"kind":       "Lock",
+		"metadata":   map[string]interface{}{"name": lockName},
+		"packages": []interface{}{
+			map[string]interface{}{
+				"name":   "platform-ref-aws-0a1b2c3d4e5f",
+				"source": "xpkg.upbound.io/upbound/platform-ref-aws",
+				"dependencies": []interface{}{
+					map[string]interface{}{"package": "xpkg.upbound.io/upbound/provider-family-aws", "constraints": ">=v1", "type": "Provider"},
+					map[string]interface{}{"package": "upbound/provider-aws-s3", "constraints": ">=v1", "type": "Provider"},
+				},
+			},
+		},
+	}}
+	m := &manager{Client: newFakeClient(
+		lock,
+		newPackageObject("Configuration", "platform-ref-aws", map[string]interface{}{"package": "xpkg.upbound.io/upbound/platform-ref-aws:v1.4.0"}, nil),
+		newPackageObject("Provider", "upbound-provider-family-aws", map[string]interface{}{"package": "xpkg.upbound.io/upbound/provider-family-aws:v1.1.0"}, nil),
+		newPackageObject("Provider", "upbound-provider-aws-s3", map[string]interface{}{"package": "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0"}, nil),
+		newPackageObject("Provider", "provider-helm", map[string]interface{}{"package": "xpkg.upbound.io/upbound/provider-helm:v0.20.4"}, nil),
+	)}
+
+	cfg := &config.Config{Configurations: []config.Provider{{Name: "platform-ref-aws", Package: "xpkg.upbound.io/upbound/platform-ref-aws", Version: "v1.4.0"}}}
+	plan, err := BuildPlan(context.Background(), m, cfg, true)
+	assert.NoError(t, err)
+	assert.Len(t, plan.Changes, 1)
+	assert.Equal(t, ActionPrune, plan.Changes[0].Action)
+	assert.Equal(t, "provider-helm", plan.Changes[0].Name)
+}
+
+func TestBuildPlanUpgradesDriftedPackages(t *testing.T) {
+	helm := config.Provider{Name: "provider-helm", Package: "xpkg.upbound.io/upbound/provider-helm", Version: "v0.20.4"}
+	m := &mockManager{
+		ListPackagesFunc: func(ctx context.Context, kind config.PackageKind) ([]config.Provider, error) {
+			if kind == config.ProviderKind {
+				return []config.Provider{helm}, nil
+			}
+			return nil, nil
+		},
+		DriftedFunc: func(ctx context.Context, kind config.PackageKind, p config.Provider) (bool, error) {
+			return p.Name == helm.Name, nil
+		},
+	}
+
+	plan, err := BuildPlan(context.Background(), m, &config.Config{OtherProviders: []config.Provider{helm}}, false)
+	assert.NoError(t, err)
+	assert.Len(t, plan.Changes, 1)
+	assert.Equal(t, ActionUpgrade, plan.Changes[0].Action)
+	assert.Equal(t, helm.Ref(), plan.Changes[0].Desired.Ref())
+}
@@ -0,0 +1,119 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// secretGVR is the GroupVersionResource of Secrets
+var secretGVR = schema.GroupVersionResource{
+	Version:  "v1",
+	Resource: "secrets",
+}
+
+// providerConfigGVR returns the GroupVersionResource of a ProviderConfig kind,
+// e.g. providerconfigs.aws.upbound.io
+func providerConfigGVR(pc config.ProviderConfig) schema.GroupVersionResource {
+	_, version, _ := strings.Cut(pc.APIVersion, "/")
+	return schema.GroupVersionResource{
+		Group:    pc.Group(),
+		Version:  version,
+		Resource: strings.ToLower(pc.ProviderConfigKind()) + "s",
+	}
+}
+
+// ApplyProviderConfig waits for the CRD of the ProviderConfig kind to be
+// established, then creates or updates the credentials Secret in the
+// Crossplane namespace and the ProviderConfig referencing it
+func (m *manager) ApplyProviderConfig(ctx context.Context, pc config.ProviderConfig) error {
+	credentials, err := pc.Credentials.Resolve()
+	if err != nil {
+		return fmt.Errorf("failed to resolve credentials of provider config %s: %v", pc.Name, err)
+	}
+
+	gvr := providerConfigGVR(pc)
+	crdName := gvr.Resource + "." + gvr.Group
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, ProviderTimeout)
+	defer cancel()
+
+	if err := m.waiter(crdGVR, "").WaitForCondition(timeoutCtx, "Established", nil, crdName); err != nil {
+		if timeoutCtx.Err() != nil {
+			return fmt.Errorf("timeout waiting for CRD %s to be established", crdName)
+		}
+		return fmt.Errorf("failed waiting for CRD %s: %v", crdName, err)
+	}
+
+	secret := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata": map[string]interface{}{
+				"name":      pc.SecretName(),
+				"namespace": m.namespace,
+			},
+			"type": "Opaque",
+			"stringData": map[string]interface{}{
+				pc.SecretKey(): string(credentials),
+			},
+		},
+	}
+	if err := m.apply(ctx, secretGVR, m.namespace, secret); err != nil {
+		return fmt.Errorf("failed to apply secret %s: %v", pc.SecretName(), err)
+	}
+
+	spec := map[string]interface{}{}
+	for k, v := range pc.Spec {
+		spec[k] = v
+	}
+	spec["credentials"] = map[string]interface{}{
+		"source": "Secret",
+		"secretRef": map[string]interface{}{
+			"namespace": m.namespace,
+			"name":      pc.SecretName(),
+			"key":       pc.SecretKey(),
+		},
+	}
+
+	obj := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": pc.APIVersion,
+			"kind":       pc.ProviderConfigKind(),
+			"metadata": map[string]interface{}{
+				"name": pc.Name,
+			},
+			"spec": spec,
+		},
+	}
+	if err := m.apply(ctx, gvr, "", obj); err != nil {
+		return fmt.Errorf("failed to apply %s %s: %v", pc.ProviderConfigKind(), pc.Name, err)
+	}
+
+	return nil
+}
+
+// apply creates an object, or replaces it if it already exists
+func (m *manager) apply(ctx context.Context, gvr schema.GroupVersionResource, namespace string, obj *unstructured.Unstructured) error {
+	client := m.Client.Resource(gvr).Namespace(namespace)
+
+	existing, err := client.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, obj, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	_, err = client.Update(ctx, obj, metav1.UpdateOptions{})
+	return err
+}
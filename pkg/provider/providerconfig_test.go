@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestApplyProviderConfig(t *testing.T) {
+	crd := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "apiextensions.k8s.io/v1",
+			"kind":       "CustomResourceDefinition",
+			"metadata":   map[string]interface{}{"name": "providerconfigs.aws.upbound.io"},
+			"status": map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Established", "status": "True"},
+				},
+			},
+		},
+	}
+	pc := config.ProviderConfig{
+		Name:        "default",
+		APIVersion:  "aws.upbound.io/v1beta1",
+		Credentials: config.CredentialsSource{Source: config.AnonymousSource},
+		Spec:        map[string]interface{}{"skip_credentials_validation": true},
+	}
+	ctx := context.Background()
+
+	m := &manager{Client: newFakeClient(crd), namespace: CrossplaneNamespace}
+	assert.NoError(t, m.ApplyProviderConfig(ctx, pc))
+
+	secret, err := m.Client.Resource(secretGVR).Namespace(CrossplaneNamespace).Get(ctx, "aws-default-credentials", metav1.GetOptions{})
+	assert.NoError(t, err)
+	data, _, _ := unstructured.NestedString(secret.Object, "stringData", "credentials")
+	assert.Contains(t, data, "aws_secret_access_key = test")
+
+	obj, err := m.Client.Resource(providerConfigGVR(pc)).Get(ctx, "default", metav1.GetOptions{})
+	assert.NoError(t, err)
+	name, _, _ := unstructured.NestedString(obj.Object, "spec", "credentials", "secretRef", "name")
+	assert.Equal(t, "aws-default-credentials", name)
+	skip, _, _ := unstructured.NestedBool(obj.Object, "spec", "skip_credentials_validation")
+	assert.True(t, skip)
+
+	// Applying again updates the existing objects
+	pc.Credentials.SecretKey = "creds"
+	assert.NoError(t, m.ApplyProviderConfig(ctx, pc))
+	obj, err = m.Client.Resource(providerConfigGVR(pc)).Get(ctx, "default", metav1.GetOptions{})
+	assert.NoError(t, err)
+	key, _, _ := unstructured.NestedString(obj.Object, "spec", "credentials", "secretRef", "key")
+	assert.Equal(t, "creds", key)
+}
@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ApplyRegistrySecret creates or updates the dockerconfigjson pull secret of
+// a private registry in the Crossplane namespace, creating the namespace first
+// so the secret exists before Crossplane is installed
+func (m *manager) ApplyRegistrySecret(ctx context.Context, auth config.RegistryAuth) error {
+	dockerConfig, err := auth.DockerConfigJSON()
+	if err != nil {
+		return fmt.Errorf("failed to resolve credentials of %s: %v", auth.Registry, err)
+	}
+
+	if err := m.ensureNamespace(ctx); err != nil {
+		return err
+	}
+
+	secret := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata": map[string]interface{}{
+				"name":      auth.SecretName(),
+				"namespace": m.namespace,
+			},
+			"type": "kubernetes.io/dockerconfigjson",
+			"stringData": map[string]interface{}{
+				".dockerconfigjson": string(dockerConfig),
+			},
+		},
+	}
+	if err := m.apply(ctx, secretGVR, m.namespace, secret); err != nil {
+		return fmt.Errorf("failed to apply secret %s: %v", auth.SecretName(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestApplyRegistrySecret(t *testing.T) {
+	t.Setenv("REGISTRY_USERNAME", "user")
+	t.Setenv("REGISTRY_PASSWORD", "secret")
+
+	auth := config.RegistryAuth{Registry: "registry.example.com", Source: config.RegistryEnvSource}
+	ctx := context.Background()
+
+	m := &manager{Client: newFakeClient(), namespace: CrossplaneNamespace}
+	assert.NoError(t, m.ApplyRegistrySecret(ctx, auth))
+	// Applying again updates the secret in the existing namespace
+	assert.NoError(t, m.ApplyRegistrySecret(ctx, auth))
+
+	secret, err := m.Client.Resource(secretGVR).Namespace(CrossplaneNamespace).Get(ctx, "registry-example-com", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "kubernetes.io/dockerconfigjson", secret.Object["type"])
+	data, _, _ := unstructured.NestedString(secret.Object, "stringData", ".dockerconfigjson")
+	assert.Contains(t, data, "registry.example.com")
+}
@@ -0,0 +1,253 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// runtimeContainer is the name Crossplane gives the package runtime container
+	runtimeContainer = "package-runtime"
+	// runtimeFilesVolume is the volume of the files mounted into the package runtime
+	runtimeFilesVolume = "crosslab-files"
+	// managedByLabel marks the runtime objects crosslab creates, so that it
+	// only removes its own when they are no longer configured
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedBy      = "crosslab"
+	// defaultRuntimeConfig is the DeploymentRuntimeConfig Crossplane
+	// references from packages that do not set one
+	defaultRuntimeConfig = "default"
+)
+
+// runtimeConfigGVR is the GroupVersionResource of DeploymentRuntimeConfigs
+var runtimeConfigGVR = schema.GroupVersionResource{
+	Group:    "pkg.crossplane.io",
+	Version:  "v1beta1",
+	Resource: "deploymentruntimeconfigs",
+}
+
+// configMapGVR is the GroupVersionResource of ConfigMaps
+var configMapGVR = schema.GroupVersionResource{
+	Version:  "v1",
+	Resource: "configmaps",
+}
+
+// applyRuntimeConfig creates or updates the DeploymentRuntimeConfig of a
+// package, named after the package, and the ConfigMap of its mounted files
+func (m *manager) applyRuntimeConfig(ctx context.Context, pkg config.Provider) error {
+	runtimeConfig, configMap, err := runtimeConfigObjects(pkg.Name, m.namespace, *pkg.RuntimeConfig)
+	if err != nil {
+		return err
+	}
+
+	if configMap != nil {
+		if err := m.apply(ctx, configMapGVR, m.namespace, configMap); err != nil {
+			return fmt.Errorf("failed to apply configmap %s: %v", configMap.GetName(), err)
+		}
+	}
+
+	if err := m.apply(ctx, runtimeConfigGVR, "", runtimeConfig); err != nil {
+		return fmt.Errorf("failed to apply DeploymentRuntimeConfig %s: %v", pkg.Name, err)
+	}
+
+	return nil
+}
+
+// runtimeConfigDrifted reports whether the runtime configuration of an
+// installed package differs from the one rendered from pkg: either the
+// package references another DeploymentRuntimeConfig, or the contents of the
+// DeploymentRuntimeConfig or of its files ConfigMap changed
+func (m *manager) runtimeConfigDrifted(ctx context.Context, obj *unstructured.Unstructured, pkg config.Provider) (bool, error) {
+	ref, _, _ := unstructured.NestedString(obj.Object, "spec", "runtimeConfigRef", "name")
+	if pkg.RuntimeConfig == nil {
+		return ref != "" && ref != defaultRuntimeConfig, nil
+	}
+	if ref != pkg.Name {
+		return true, nil
+	}
+
+	runtimeConfig, configMap, err := runtimeConfigObjects(pkg.Name, m.namespace, *pkg.RuntimeConfig)
+	if err != nil {
+		return false, err
+	}
+
+	drifted, err := m.fieldDrifted(ctx, runtimeConfigGVR, "", runtimeConfig, "spec")
+	if err != nil || drifted || configMap == nil {
+		return drifted, err
+	}
+	return m.fieldDrifted(ctx, configMapGVR, m.namespace, configMap, "data")
+}
+
+// fieldDrifted reports whether a top-level field of an object in the cluster
+// differs from the desired object, or whether the object is missing
+func (m *manager) fieldDrifted(ctx context.Context, gvr schema.GroupVersionResource, namespace string, desired *unstructured.Unstructured, field string) (bool, error) {
+	live, err := m.Client.Resource(gvr).Namespace(namespace).Get(ctx, desired.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get %s %s: %v", desired.GetKind(), desired.GetName(), err)
+	}
+
+	// Compare the JSON forms, as the configuration may hold numbers the
+	// cluster returns with another Go type
+	want, err := json.Marshal(desired.Object[field])
+	if err != nil {
+		return false, err
+	}
+	got, err := json.Marshal(live.Object[field])
+	if err != nil {
+		return false, err
+	}
+	var wantValue, gotValue interface{}
+	if err := json.Unmarshal(want, &wantValue); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		return false, err
+	}
+	return !reflect.DeepEqual(wantValue, gotValue), nil
+}
+
+// pruneRuntimeConfig deletes the DeploymentRuntimeConfig and the files
+// ConfigMap crosslab created for a package once its configuration no longer
+// needs them. Call it after the package stopped referencing them.
+func (m *manager) pruneRuntimeConfig(ctx context.Context, pkg config.Provider) error {
+	if pkg.RuntimeConfig == nil {
+		if err := m.deleteManaged(ctx, runtimeConfigGVR, "", pkg.Name); err != nil {
+			return fmt.Errorf("failed to delete DeploymentRuntimeConfig %s: %v", pkg.Name, err)
+		}
+	}
+
+	if pkg.RuntimeConfig == nil || len(pkg.RuntimeConfig.Files) == 0 {
+		name := runtimeFilesName(pkg.Name)
+		if err := m.deleteManaged(ctx, configMapGVR, m.namespace, name); err != nil {
+			return fmt.Errorf("failed to delete configmap %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// deleteManaged deletes an object if it exists and was created by crosslab
+func (m *manager) deleteManaged(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) error {
+	client := m.Client.Resource(gvr).Namespace(namespace)
+
+	obj, err := client.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if obj.GetLabels()[managedByLabel] != managedBy {
+		return nil
+	}
+
+	err = client.Delete(ctx, name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// runtimeFilesName returns the name of the ConfigMap holding the files of a package
+func runtimeFilesName(name string) string {
+	return name + "-files"
+}
+
+// runtimeConfigObjects builds the DeploymentRuntimeConfig of a package and,
+// when files are mounted, the ConfigMap holding them
+func runtimeConfigObjects(name, namespace string, r config.RuntimeConfig) (*unstructured.Unstructured, *unstructured.Unstructured, error) {
+	container := map[string]interface{}{
+		"name": runtimeContainer,
+	}
+	if args := r.ContainerArgs(); len(args) > 0 {
+		list := make([]interface{}, 0, len(args))
+		for _, arg := range args {
+			list = append(list, arg)
+		}
+		container["args"] = list
+	}
+	if r.Resources != nil {
+		container["resources"] = r.Resources
+	}
+
+	podSpec := map[string]interface{}{
+		"containers": []interface{}{container},
+	}
+
+	var configMap *unstructured.Unstructured
+	if len(r.Files) > 0 {
+		data := map[string]interface{}{}
+		mounts := make([]interface{}, 0, len(r.Files))
+		for i, f := range r.Files {
+			raw, err := os.ReadFile(f.Path)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to read runtime file: %v", err)
+			}
+			key := fmt.Sprintf("file-%d", i)
+			data[key] = string(raw)
+			mounts = append(mounts, map[string]interface{}{
+				"name":      runtimeFilesVolume,
+				"mountPath": f.MountPath,
+				"subPath":   key,
+				"readOnly":  true,
+			})
+		}
+
+		configMap = &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata": map[string]interface{}{
+					"name":      runtimeFilesName(name),
+					"namespace": namespace,
+					"labels":    map[string]interface{}{managedByLabel: managedBy},
+				},
+				"data": data,
+			},
+		}
+
+		container["volumeMounts"] = mounts
+		podSpec["volumes"] = []interface{}{
+			map[string]interface{}{
+				"name":      runtimeFilesVolume,
+				"configMap": map[string]interface{}{"name": configMap.GetName()},
+			},
+		}
+	}
+
+	runtimeConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": runtimeConfigGVR.GroupVersion().String(),
+			"kind":       "DeploymentRuntimeConfig",
+			"metadata": map[string]interface{}{
+				"name":   name,
+				"labels": map[string]interface{}{managedByLabel: managedBy},
+			},
+			"spec": map[string]interface{}{
+				"deploymentTemplate": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"selector": map[string]interface{}{},
+						"template": map[string]interface{}{
+							"spec": podSpec,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return runtimeConfig, configMap, nil
+}
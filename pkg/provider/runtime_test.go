@@ -0,0 +1,142 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestInstallPackageRuntimeConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ca.crt")
+	assert.NoError(t, os.WriteFile(file, []byte("certificate"), 0644))
+
+	debug := true
+	pkg := config.Provider{
+		Name:    "provider-aws-s3",
+		Package: "xpkg.upbound.io/upbound/provider-aws-s3",
+		Version: "v1",
+		RuntimeConfig: &config.RuntimeConfig{
+			Debug:            &debug,
+			Poll:             "1m",
+			MaxReconcileRate: 5,
+			Resources:        map[string]interface{}{"requests": map[string]interface{}{"cpu": "100m"}},
+			Files:            []config.RuntimeFile{{Path: file, MountPath: "/etc/ssl/certs/ca.crt"}},
+		},
+	}
+	ctx := context.Background()
+
+	m := &manager{Client: newFakeClient(), namespace: CrossplaneNamespace}
+	assert.NoError(t, m.InstallPackage(ctx, config.ProviderKind, pkg, false))
+
+	obj, err := m.Client.Resource(packageGVR(config.ProviderKind)).Get(ctx, pkg.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	ref, _, _ := unstructured.NestedString(obj.Object, "spec", "runtimeConfigRef", "name")
+	assert.Equal(t, pkg.Name, ref)
+
+	runtimeConfig, err := m.Client.Resource(runtimeConfigGVR).Get(ctx, pkg.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	containers, _, _ := unstructured.NestedSlice(runtimeConfig.Object, "spec", "deploymentTemplate", "spec", "template", "spec", "containers")
+	container := containers[0].(map[string]interface{})
+	assert.Equal(t, runtimeContainer, container["name"])
+	assert.Equal(t, []interface{}{"--debug", "--poll=1m", "--max-reconcile-rate=5"}, container["args"])
+	assert.Equal(t, pkg.RuntimeConfig.Resources, container["resources"])
+	mount := container["volumeMounts"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "/etc/ssl/certs/ca.crt", mount["mountPath"])
+
+	configMap, err := m.Client.Resource(configMapGVR).Namespace(CrossplaneNamespace).Get(ctx, pkg.Name+"-files", metav1.GetOptions{})
+	assert.NoError(t, err)
+	data, _, _ := unstructured.NestedString(configMap.Object, "data", mount["subPath"].(string))
+	assert.Equal(t, "certificate", data)
+
+	// Packages without a runtime configuration use Crossplane's default
+	assert.NotContains(t, packageSpec(config.Provider{Name: "provider-helm", Package: "xpkg.upbound.io/upbound/provider-helm"}), "runtimeConfigRef")
+}
+
+func TestDriftedRuntimeConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ca.crt")
+	assert.NoError(t, os.WriteFile(file, []byte("certificate"), 0644))
+
+	pkg := config.Provider{
+		Name:    "provider-aws-s3",
+		Package: "xpkg.upbound.io/upbound/provider-aws-s3",
+		Version: "v1",
+		RuntimeConfig: &config.RuntimeConfig{
+			MaxReconcileRate: 5,
+			Resources:        map[string]interface{}{"limits": map[string]interface{}{"cpu": int64(1)}},
+			Files:            []config.RuntimeFile{{Path: file, MountPath: "/etc/ssl/certs/ca.crt"}},
+		},
+	}
+	ctx := context.Background()
+
+	m := &manager{Client: newFakeClient(), namespace: CrossplaneNamespace}
+	assert.NoError(t, m.InstallPackage(ctx, config.ProviderKind, pkg, false))
+
+	drifted, err := m.Drifted(ctx, config.ProviderKind, pkg)
+	assert.NoError(t, err)
+	assert.False(t, drifted)
+
+	changed := pkg
+	changed.RuntimeConfig = &config.RuntimeConfig{MaxReconcileRate: 10, Resources: pkg.RuntimeConfig.Resources, Files: pkg.RuntimeConfig.Files}
+	drifted, err = m.Drifted(ctx, config.ProviderKind, changed)
+	assert.NoError(t, err)
+	assert.True(t, drifted, "container args changed")
+
+	assert.NoError(t, os.WriteFile(file, []byte("renewed certificate"), 0644))
+	drifted, err = m.Drifted(ctx, config.ProviderKind, pkg)
+	assert.NoError(t, err)
+	assert.True(t, drifted, "mounted file changed")
+
+	removed := pkg
+	removed.RuntimeConfig = nil
+	drifted, err = m.Drifted(ctx, config.ProviderKind, removed)
+	assert.NoError(t, err)
+	assert.True(t, drifted, "runtime configuration removed")
+
+	// Crossplane references its default runtime configuration when none is set
+	helm := config.Provider{Name: "provider-helm", Package: "xpkg.upbound.io/upbound/provider-helm", Version: "v0.20.4"}
+	m = &manager{Client: newFakeClient(newPackageObject("Provider", helm.Name, map[string]interface{}{
+		"package":          helm.Ref(),
+		"runtimeConfigRef": map[string]interface{}{"name": defaultRuntimeConfig},
+	}, nil)), namespace: CrossplaneNamespace}
+	drifted, err = m.Drifted(ctx, config.ProviderKind, helm)
+	assert.NoError(t, err)
+	assert.False(t, drifted)
+}
+
+func TestUpgradeRemovesRuntimeConfig(t *testing.T) {
+	pkg := config.Provider{Name: "provider-aws-s3", Package: "xpkg.upbound.io/upbound/provider-aws-s3", Version: "v1.1.0"}
+	runtimeConfig, configMap, err := runtimeConfigObjects(pkg.Name, CrossplaneNamespace, config.RuntimeConfig{
+		Files: []config.RuntimeFile{{Path: "/dev/null", MountPath: "/etc/ssl/certs/ca.crt"}},
+	})
+	assert.NoError(t, err)
+
+	provider := newPackageObject("Provider", pkg.Name, map[string]interface{}{
+		"package":          "xpkg.upbound.io/upbound/provider-aws-s3:v1.0.0",
+		"runtimeConfigRef": map[string]interface{}{"name": pkg.Name},
+	}, nil, "Installed", "Healthy")
+	revision := newPackageObject("ProviderRevision", "provider-aws-s3-abc123",
+		map[string]interface{}{"image": pkg.Ref(), "desiredState": "Active"},
+		map[string]interface{}{PackageLabel: pkg.Name}, "Healthy")
+
+	m := &manager{Client: newFakeClient(provider, revision, runtimeConfig, configMap), namespace: CrossplaneNamespace}
+	ctx := context.Background()
+	assert.NoError(t, m.Upgrade(ctx, config.ProviderKind, pkg))
+
+	obj, err := m.Client.Resource(packageGVR(config.ProviderKind)).Get(ctx, pkg.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	_, found, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "runtimeConfigRef")
+	assert.False(t, found)
+
+	_, err = m.Client.Resource(runtimeConfigGVR).Get(ctx, pkg.Name, metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+	_, err = m.Client.Resource(configMapGVR).Namespace(CrossplaneNamespace).Get(ctx, runtimeFilesName(pkg.Name), metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
@@ -0,0 +1,268 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// crdGVR is the GroupVersionResource of CustomResourceDefinitions
+var crdGVR = schema.GroupVersionResource{
+	Group:    "apiextensions.k8s.io",
+	Version:  "v1",
+	Resource: "customresourcedefinitions",
+}
+
+// Condition is a status condition reported by Crossplane on a package
+type Condition struct {
+	Status             string    `json:"status" yaml:"status"`
+	Reason             string    `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Message            string    `json:"message,omitempty" yaml:"message,omitempty"`
+	LastTransitionTime time.Time `json:"lastTransitionTime,omitempty" yaml:"lastTransitionTime,omitempty"`
+}
+
+// True reports whether the condition status is True
+func (c Condition) True() bool {
+	return c.Status == "True"
+}
+
+// PackageStatus describes the state of an installed Crossplane package
+type PackageStatus struct {
+	Kind      config.PackageKind `json:"kind" yaml:"kind"`
+	Name      string             `json:"name" yaml:"name"`
+	Package   string             `json:"package" yaml:"package"`
+	Version   string             `json:"version" yaml:"version"`
+	Installed Condition          `json:"installed" yaml:"installed"`
+	Healthy   Condition          `json:"healthy" yaml:"healthy"`
+	// Revision is the name of the current package revision
+	Revision string `json:"revision,omitempty" yaml:"revision,omitempty"`
+	// ImageDigest is the digest of the current revision's package image, or
+	// empty when the revision records only a tag
+	ImageDigest string `json:"imageDigest,omitempty" yaml:"imageDigest,omitempty"`
+	// ImageDigestPrefix is the start of the image digest that Crossplane puts
+	// in the revision name, set when the full digest is not recorded
+	ImageDigestPrefix string `json:"imageDigestPrefix,omitempty" yaml:"imageDigestPrefix,omitempty"`
+	// CRDs is the number of CustomResourceDefinitions owned by the current revision
+	CRDs      int       `json:"crds" yaml:"crds"`
+	CreatedAt time.Time `json:"createdAt" yaml:"createdAt"`
+}
+
+// Age returns how long ago the package was created
+func (s PackageStatus) Age() time.Duration {
+	return time.Since(s.CreatedAt)
+}
+
+// Status returns the status of an installed package, or nil if it is not installed
+func (m *manager) Status(ctx context.Context, kind config.PackageKind, name string) (*PackageStatus, error) {
+	obj, err := m.Client.Resource(packageGVR(kind)).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %s: %v", strings.ToLower(string(kind)), name, err)
+	}
+
+	statuses, err := m.packageStatuses(ctx, kind, []unstructured.Unstructured{*obj})
+	if err != nil {
+		return nil, err
+	}
+
+	return &statuses[0], nil
+}
+
+// ListStatus returns the status of every installed package of the given kind
+func (m *manager) ListStatus(ctx context.Context, kind config.PackageKind) ([]PackageStatus, error) {
+	list, err := m.Client.Resource(packageGVR(kind)).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %ss: %v", strings.ToLower(string(kind)), err)
+	}
+
+	return m.packageStatuses(ctx, kind, list.Items)
+}
+
+// packageStatuses builds the status of the given package objects, looking up
+// their current revisions and the CRDs those revisions own
+func (m *manager) packageStatuses(ctx context.Context, kind config.PackageKind, objects []unstructured.Unstructured) ([]PackageStatus, error) {
+	revisions := map[string]*unstructured.Unstructured{}
+	revList, err := m.Client.Resource(packageRevisionGVR(kind)).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s revisions: %v", strings.ToLower(string(kind)), err)
+	}
+	for i := range revList.Items {
+		revisions[revList.Items[i].GetName()] = &revList.Items[i]
+	}
+
+	crdCounts := map[string]int{}
+	crdList, err := m.Client.Resource(crdGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list CRDs: %v", err)
+	}
+	for _, crd := range crdList.Items {
+		for _, ref := range crd.GetOwnerReferences() {
+			if ref.Kind == string(kind)+"Revision" {
+				crdCounts[ref.Name]++
+			}
+		}
+	}
+
+	statuses := make([]PackageStatus, 0, len(objects))
+	for i := range objects {
+		obj := &objects[i]
+		ref, _, _ := unstructured.NestedString(obj.Object, "spec", "package")
+		pkg, version := parsePackageRef(ref)
+		revision, _, _ := unstructured.NestedString(obj.Object, "status", "currentRevision")
+
+		status := PackageStatus{
+			Kind:      kind,
+			Name:      obj.GetName(),
+			Package:   pkg,
+			Version:   version,
+			Installed: getCondition(obj, "Installed"),
+			Healthy:   getCondition(obj, "Healthy"),
+			Revision:  revision,
+			CRDs:      crdCounts[revision],
+			CreatedAt: obj.GetCreationTimestamp().Time,
+		}
+		if rev, ok := revisions[revision]; ok {
+			status.ImageDigest = revisionDigest(rev)
+		}
+		if status.ImageDigest == "" {
+			status.ImageDigestPrefix = revisionDigestPrefix(revision)
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// revisionDigest returns the image digest of a package revision, read from
+// the package Crossplane resolved or an image pinned by digest. It is empty
+// when the revision records only a tag.
+func revisionDigest(rev *unstructured.Unstructured) string {
+	resolved, _, _ := unstructured.NestedString(rev.Object, "status", "resolvedPackage")
+	image, _, _ := unstructured.NestedString(rev.Object, "spec", "image")
+	for _, ref := range []string{resolved, image} {
+		if i := strings.Index(ref, "@"); i >= 0 {
+			return ref[i+1:]
+		}
+	}
+	return ""
+}
+
+// revisionDigestPrefix returns the image digest prefix Crossplane appends to
+// a revision name (e.g. provider-helm-0a1b2c3d4e5f), or empty if the name has
+// none
+func revisionDigestPrefix(revision string) string {
+	i := strings.LastIndex(revision, "-")
+	if i < 0 {
+		return ""
+	}
+	prefix := revision[i+1:]
+	if len(prefix) != 12 || strings.Trim(prefix, "0123456789abcdef") != "" {
+		return ""
+	}
+	return "sha256:" + prefix
+}
+
+// getCondition returns the status condition of the given type, or an
+// Unknown condition if the object does not report it
+func getCondition(obj *unstructured.Unstructured, conditionType string) Condition {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != conditionType {
+			continue
+		}
+
+		result := Condition{}
+		result.Status, _ = condition["status"].(string)
+		result.Reason, _ = condition["reason"].(string)
+		result.Message, _ = condition["message"].(string)
+		if ts, ok := condition["lastTransitionTime"].(string); ok {
+			result.LastTransitionTime, _ = time.Parse(time.RFC3339, ts)
+		}
+		return result
+	}
+
+	return Condition{Status: "Unknown"}
+}
+
+// CrossplaneStatus describes the Crossplane Helm release and core deployment
+type CrossplaneStatus struct {
+	// Installed reports whether the Crossplane Helm release exists
+	Installed     bool   `json:"installed" yaml:"installed"`
+	ChartVersion  string `json:"chartVersion,omitempty" yaml:"chartVersion,omitempty"`
+	AppVersion    string `json:"appVersion,omitempty" yaml:"appVersion,omitempty"`
+	ReleaseStatus string `json:"releaseStatus,omitempty" yaml:"releaseStatus,omitempty"`
+	Revision      int    `json:"revision,omitempty" yaml:"revision,omitempty"`
+	// Available is the Available condition of the Crossplane deployment
+	Available     Condition `json:"available" yaml:"available"`
+	ReadyReplicas int64     `json:"readyReplicas" yaml:"readyReplicas"`
+	Replicas      int64     `json:"replicas" yaml:"replicas"`
+}
+
+// Healthy reports whether the release is deployed and the deployment available
+func (s CrossplaneStatus) Healthy() bool {
+	return s.Installed && s.ReleaseStatus == release.StatusDeployed.String() && s.Available.True()
+}
+
+// CrossplaneStatus returns the state of the Crossplane release and deployment
+func (m *manager) CrossplaneStatus(ctx context.Context) (*CrossplaneStatus, error) {
+	_, actionConfig, err := m.helmConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &CrossplaneStatus{Available: Condition{Status: "Unknown"}}
+	rel, err := action.NewStatus(actionConfig).Run(CrossplaneChartName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Crossplane release: %v", err)
+	}
+
+	status.Installed = true
+	status.ReleaseStatus = rel.Info.Status.String()
+	status.Revision = rel.Version
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		status.ChartVersion = rel.Chart.Metadata.Version
+		status.AppVersion = rel.Chart.Metadata.AppVersion
+	}
+
+	if err := m.deploymentStatus(ctx, status); err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// deploymentStatus fills in the availability of the Crossplane deployment
+func (m *manager) deploymentStatus(ctx context.Context, status *CrossplaneStatus) error {
+	obj, err := m.Client.Resource(deploymentGVR).Namespace(m.namespace).Get(ctx, CrossplaneDeployment, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		status.Available = Condition{Status: "False", Reason: "NotFound", Message: "the Crossplane deployment does not exist"}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get Crossplane deployment: %v", err)
+	}
+
+	status.Available = getCondition(obj, "Available")
+	status.ReadyReplicas, _, _ = unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+	status.Replicas, _, _ = unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	return nil
+}
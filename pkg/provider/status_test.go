@@ -0,0 +1,143 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// newCRDObject builds a CRD owned by the given provider revision
+func newCRDObject(name string, revision string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "apiextensions.k8s.io/v1",
+			"kind":       "CustomResourceDefinition",
+			"metadata": map[string]interface{}{
+				"name": name,
+				"ownerReferences": []interface{}{
+					map[string]interface{}{
+						"apiVersion": "pkg.crossplane.io/v1",
+						"kind":       "ProviderRevision",
+						"name":       revision,
+						"uid":        revision,
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestStatus(t *testing.T) {
+	provider := newPackageObject("Provider", "provider-aws-s3",
+		map[string]interface{}{"package": "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0"}, nil, "Installed")
+	_ = unstructured.SetNestedField(provider.Object, "provider-aws-s3-3b55d2f5bcfe", "status", "currentRevision")
+	_ = unstructured.SetNestedSlice(provider.Object, []interface{}{
+		map[string]interface{}{"type": "Installed", "status": "True", "reason": "ActivePackageRevision"},
+		map[string]interface{}{"type": "Healthy", "status": "False", "reason": "UnhealthyPackageRevision", "message": "cannot pull image"},
+	}, "status", "conditions")
+
+	revision := newPackageObject("ProviderRevision", "provider-aws-s3-3b55d2f5bcfe",
+		map[string]interface{}{"image": "xpkg.upbound.io/upbound/provider-aws-s3:v1.1.0", "desiredState": "Active"},
+		map[string]interface{}{PackageLabel: "provider-aws-s3"})
+	_ = unstructured.SetNestedField(revision.Object,
+		"xpkg.upbound.io/upbound/provider-aws-s3@sha256:3b55d2f5bcfe0c8a5b0e8f3e5e4b0f7c5b5a0a4f4d1b2c3d4e5f60718293a4b5", "status", "resolvedPackage")
+
+	m := &manager{Client: newFakeClient(
+		provider,
+		revision,
+		newCRDObject("buckets.s3.aws.upbound.io", "provider-aws-s3-3b55d2f5bcfe"),
+		newCRDObject("bucketpolicies.s3.aws.upbound.io", "provider-aws-s3-3b55d2f5bcfe"),
+		newCRDObject("roles.iam.aws.upbound.io", "provider-aws-iam-aaaaaaaaaaaa"),
+	)}
+	ctx := context.Background()
+
+	status, err := m.Status(ctx, config.ProviderKind, "provider-aws-s3")
+	assert.NoError(t, err)
+	assert.Equal(t, "xpkg.upbound.io/upbound/provider-aws-s3", status.Package)
+	assert.Equal(t, "v1.1.0", status.Version)
+	assert.True(t, status.Installed.True())
+	assert.False(t, status.Healthy.True())
+	assert.Equal(t, "UnhealthyPackageRevision", status.Healthy.Reason)
+	assert.Equal(t, "cannot pull image", status.Healthy.Message)
+	assert.Equal(t, "provider-aws-s3-3b55d2f5bcfe", status.Revision)
+	assert.Equal(t, "sha256:3b55d2f5bcfe0c8a5b0e8f3e5e4b0f7c5b5a0a4f4d1b2c3d4e5f60718293a4b5", status.ImageDigest)
+	assert.Equal(t, 2, status.CRDs)
+
+	missing, err := m.Status(ctx, config.ProviderKind, "provider-aws-ec2")
+	assert.NoError(t, err)
+	assert.Nil(t, missing)
+
+	statuses, err := m.ListStatus(ctx, config.ProviderKind)
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 1)
+}
+
+func TestRevisionDigest(t *testing.T) {
+	pinned := newPackageObject("ProviderRevision", "provider-helm-0a1b2c3d4e5f",
+		map[string]interface{}{"image": "xpkg.upbound.io/upbound/provider-helm@sha256:0a1b2c3d4e5f"}, nil)
+	assert.Equal(t, "sha256:0a1b2c3d4e5f", revisionDigest(pinned))
+
+	tagged := newPackageObject("ProviderRevision", "provider-helm-0a1b2c3d4e5f",
+		map[string]interface{}{"image": "xpkg.upbound.io/upbound/provider-helm:v0.20.4"}, nil)
+	assert.Empty(t, revisionDigest(tagged))
+}
+
+func TestRevisionDigestPrefix(t *testing.T) {
+	assert.Equal(t, "sha256:0a1b2c3d4e5f", revisionDigestPrefix("provider-helm-0a1b2c3d4e5f"))
+	assert.Empty(t, revisionDigestPrefix("provider-helm"))
+	assert.Empty(t, revisionDigestPrefix(""))
+}
+
+func TestStatusTaggedRevision(t *testing.T) {
+	provider := newPackageObject("Provider", "provider-helm",
+		map[string]interface{}{"package": "xpkg.upbound.io/upbound/provider-helm:v0.20.4"}, nil)
+	_ = unstructured.SetNestedField(provider.Object, "provider-helm-0a1b2c3d4e5f", "status", "currentRevision")
+	revision := newPackageObject("ProviderRevision", "provider-helm-0a1b2c3d4e5f",
+		map[string]interface{}{"image": "xpkg.upbound.io/upbound/provider-helm:v0.20.4"},
+		map[string]interface{}{PackageLabel: "provider-helm"})
+
+	m := &manager{Client: newFakeClient(provider, revision)}
+	status, err := m.Status(context.Background(), config.ProviderKind, "provider-helm")
+	assert.NoError(t, err)
+	assert.Empty(t, status.ImageDigest)
+	assert.Equal(t, "sha256:0a1b2c3d4e5f", status.ImageDigestPrefix)
+}
+
+func TestCrossplaneDeploymentStatus(t *testing.T) {
+	deployment := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": CrossplaneDeployment, "namespace": CrossplaneNamespace},
+			"spec":       map[string]interface{}{"replicas": int64(1)},
+			"status": map[string]interface{}{
+				"readyReplicas": int64(1),
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Available", "status": "True", "reason": "MinimumReplicasAvailable"},
+				},
+			},
+		},
+	}
+	ctx := context.Background()
+
+	t.Run("available", func(t *testing.T) {
+		m := &manager{Client: newFakeClient(deployment), namespace: CrossplaneNamespace}
+		status := &CrossplaneStatus{Installed: true, ReleaseStatus: "deployed"}
+		assert.NoError(t, m.deploymentStatus(ctx, status))
+		assert.True(t, status.Available.True())
+		assert.Equal(t, int64(1), status.ReadyReplicas)
+		assert.Equal(t, int64(1), status.Replicas)
+		assert.True(t, status.Healthy())
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		m := &manager{Client: newFakeClient(), namespace: "crossplane"}
+		status := &CrossplaneStatus{Installed: true, ReleaseStatus: "deployed"}
+		assert.NoError(t, m.deploymentStatus(ctx, status))
+		assert.Equal(t, "NotFound", status.Available.Reason)
+		assert.False(t, status.Healthy())
+	})
+}
@@ -0,0 +1,251 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ConditionTransition is a change of a status condition observed on a watched object
+type ConditionTransition struct {
+	Name    string
+	Type    string
+	Status  string
+	Reason  string
+	Message string
+}
+
+// String formats the transition for progress output
+func (t ConditionTransition) String() string {
+	s := fmt.Sprintf("%s: %s=%s", t.Name, t.Type, t.Status)
+	if t.Reason != "" {
+		s += fmt.Sprintf(" (%s)", t.Reason)
+	}
+	if t.Message != "" {
+		s += ": " + t.Message
+	}
+	return s
+}
+
+// Waiter waits for objects of a single resource to reach a desired state.
+// It is backed by one informer, so a single watch serves any number of
+// concurrent waits, e.g. for every provider of a parallel install.
+type Waiter struct {
+	client    dynamic.Interface
+	gvr       schema.GroupVersionResource
+	namespace string
+
+	startOnce sync.Once
+	stopCh    chan struct{}
+	informer  cache.SharedIndexInformer
+
+	mu          sync.Mutex
+	changed     chan struct{}
+	conditions  map[string]map[string]ConditionTransition
+	subscribers map[*subscriber]struct{}
+}
+
+// subscriber receives the condition transitions of a set of objects
+type subscriber struct {
+	names        map[string]bool
+	onTransition func(ConditionTransition)
+}
+
+// NewWaiter creates a waiter for a resource. namespace may be empty for
+// cluster scoped resources.
+func NewWaiter(client dynamic.Interface, gvr schema.GroupVersionResource, namespace string) *Waiter {
+	return &Waiter{
+		client:      client,
+		gvr:         gvr,
+		namespace:   namespace,
+		stopCh:      make(chan struct{}),
+		changed:     make(chan struct{}),
+		conditions:  map[string]map[string]ConditionTransition{},
+		subscribers: map[*subscriber]struct{}{},
+	}
+}
+
+// start starts the informer the first time the waiter is used
+func (w *Waiter) start() {
+	w.startOnce.Do(func() {
+		w.informer = dynamicinformer.NewFilteredDynamicInformer(w.client, w.gvr, w.namespace, 0, cache.Indexers{}, nil).Informer()
+		w.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    w.observe,
+			UpdateFunc: func(_, obj interface{}) { w.observe(obj) },
+			DeleteFunc: w.observe,
+		})
+		go w.informer.Run(w.stopCh)
+	})
+}
+
+// Stop stops the informer backing the waiter
+func (w *Waiter) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	select {
+	case <-w.stopCh:
+	default:
+		close(w.stopCh)
+	}
+}
+
+// observe records condition transitions, reports them to subscribers and
+// wakes up pending waits
+func (w *Waiter) observe(obj interface{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		for _, t := range w.recordConditions(u) {
+			for sub := range w.subscribers {
+				if sub.names[t.Name] {
+					sub.onTransition(t)
+				}
+			}
+		}
+	}
+
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
+
+// subscribe registers onTransition for the named objects and replays the
+// conditions already observed on them. The returned function unsubscribes.
+func (w *Waiter) subscribe(names []string, onTransition func(ConditionTransition)) func() {
+	sub := &subscriber{names: map[string]bool{}, onTransition: onTransition}
+	for _, name := range names {
+		sub.names[name] = true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, name := range names {
+		for _, t := range w.conditions[name] {
+			onTransition(t)
+		}
+	}
+	w.subscribers[sub] = struct{}{}
+
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.subscribers, sub)
+	}
+}
+
+// recordConditions stores the conditions of obj and returns the ones that
+// changed since it was last seen. Callers must hold w.mu.
+func (w *Waiter) recordConditions(obj *unstructured.Unstructured) []ConditionTransition {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+
+	last := w.conditions[obj.GetName()]
+	if last == nil {
+		last = map[string]ConditionTransition{}
+		w.conditions[obj.GetName()] = last
+	}
+
+	var transitions []ConditionTransition
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		t := ConditionTransition{Name: obj.GetName()}
+		t.Type, _ = condition["type"].(string)
+		t.Status, _ = condition["status"].(string)
+		t.Reason, _ = condition["reason"].(string)
+		t.Message, _ = condition["message"].(string)
+
+		if prev, ok := last[t.Type]; ok && prev.Status == t.Status && prev.Reason == t.Reason {
+			continue
+		}
+		last[t.Type] = t
+		transitions = append(transitions, t)
+	}
+
+	return transitions
+}
+
+// until blocks until done reports true for the objects in the informer
+// cache, or ctx is done
+func (w *Waiter) until(ctx context.Context, done func(objects []*unstructured.Unstructured) bool) error {
+	w.start()
+
+	if !cache.WaitForCacheSync(ctx.Done(), w.informer.HasSynced) {
+		return fmt.Errorf("failed to sync %s watch: %v", w.gvr.Resource, ctx.Err())
+	}
+
+	for {
+		// Grab the change notification before reading the cache so that an
+		// update between the check and the select is never missed
+		w.mu.Lock()
+		changed := w.changed
+		w.mu.Unlock()
+
+		var objects []*unstructured.Unstructured
+		for _, item := range w.informer.GetStore().List() {
+			if u, ok := item.(*unstructured.Unstructured); ok {
+				objects = append(objects, u)
+			}
+		}
+
+		if done(objects) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-w.stopCh:
+			return fmt.Errorf("%s watch stopped", w.gvr.Resource)
+		case <-changed:
+		}
+	}
+}
+
+// WaitForCondition blocks until every named object reports the condition
+// type with status True, or ctx is done. onTransition, if set, is called for
+// every condition transition of the named objects while waiting.
+func (w *Waiter) WaitForCondition(ctx context.Context, conditionType string, onTransition func(ConditionTransition), names ...string) error {
+	if onTransition != nil {
+		unsubscribe := w.subscribe(names, onTransition)
+		defer unsubscribe()
+	}
+
+	return w.until(ctx, func(objects []*unstructured.Unstructured) bool {
+		ready := map[string]bool{}
+		for _, obj := range objects {
+			if conditionTrue(obj, conditionType) {
+				ready[obj.GetName()] = true
+			}
+		}
+
+		for _, name := range names {
+			if !ready[name] {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// WaitForMatch blocks until any object satisfies match, or ctx is done
+func (w *Waiter) WaitForMatch(ctx context.Context, match func(obj *unstructured.Unstructured) bool) error {
+	return w.until(ctx, func(objects []*unstructured.Unstructured) bool {
+		for _, obj := range objects {
+			if match(obj) {
+				return true
+			}
+		}
+		return false
+	})
+}
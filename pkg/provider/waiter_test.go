@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestWaiter(t *testing.T) {
+	t.Run("waits for many objects and reports transitions", func(t *testing.T) {
+		s3 := newPackageObject("Provider", "provider-aws-s3", map[string]interface{}{"package": "xpkg.upbound.io/upbound/provider-aws-s3:v1"}, nil, "Installed")
+		iam := newPackageObject("Provider", "provider-aws-iam", map[string]interface{}{"package": "xpkg.upbound.io/upbound/provider-aws-iam:v1"}, nil, "Installed", "Healthy")
+		client := newFakeClient(s3, iam)
+		gvr := packageGVR(config.ProviderKind)
+
+		w := NewWaiter(client, gvr, "")
+		defer w.Stop()
+
+		var mu sync.Mutex
+		var transitions []ConditionTransition
+		onTransition := func(tr ConditionTransition) {
+			mu.Lock()
+			defer mu.Unlock()
+			transitions = append(transitions, tr)
+		}
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			updated := s3.DeepCopy()
+			_ = unstructured.SetNestedSlice(updated.Object, []interface{}{
+				map[string]interface{}{"type": "Installed", "status": "True"},
+				map[string]interface{}{"type": "Healthy", "status": "True", "reason": "HealthyPackageRevision"},
+			}, "status", "conditions")
+			_, _ = client.Resource(gvr).Update(context.Background(), updated, metav1.UpdateOptions{})
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := w.WaitForCondition(ctx, "Healthy", onTransition, "provider-aws-s3", "provider-aws-iam")
+		assert.NoError(t, err)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Contains(t, transitions, ConditionTransition{Name: "provider-aws-s3", Type: "Installed", Status: "True"})
+		assert.Contains(t, transitions, ConditionTransition{Name: "provider-aws-s3", Type: "Healthy", Status: "True", Reason: "HealthyPackageRevision"})
+		assert.Contains(t, transitions, ConditionTransition{Name: "provider-aws-iam", Type: "Healthy", Status: "True"})
+	})
+
+	t.Run("times out when the condition is never met", func(t *testing.T) {
+		s3 := newPackageObject("Provider", "provider-aws-s3", map[string]interface{}{"package": "xpkg.upbound.io/upbound/provider-aws-s3:v1"}, nil, "Installed")
+		client := newFakeClient(s3)
+
+		w := NewWaiter(client, packageGVR(config.ProviderKind), "")
+		defer w.Stop()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		err := w.WaitForCondition(ctx, "Healthy", nil, "provider-aws-s3")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("manager waits for package health", func(t *testing.T) {
+		fn := newPackageObject("Function", "function-go-templating", map[string]interface{}{"package": "xpkg.upbound.io/crossplane-contrib/function-go-templating:v0.9.2"}, nil, "Installed", "Healthy")
+		m := &manager{Client: newFakeClient(fn)}
+
+		err := m.WaitForPackageHealth(context.Background(), config.FunctionKind, "function-go-templating")
+		assert.NoError(t, err)
+	})
+}
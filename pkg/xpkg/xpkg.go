@@ -0,0 +1,359 @@
+// Package xpkg builds Crossplane packages from directories of manifests and
+// pushes them to OCI registries
+package xpkg
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// MetaFile is the package metadata file at the root of a package directory
+	MetaFile = "crossplane.yaml"
+	// StreamFile is the file in the package layer holding all package manifests
+	StreamFile = "package.yaml"
+	// Extension is the file extension of package archives
+	Extension = ".xpkg"
+
+	// annotationKey marks the layer that holds the package manifests
+	annotationKey = "io.crossplane.xpkg"
+	// baseAnnotation is the value of annotationKey for the package layer
+	baseAnnotation = "base"
+	// metaAPIGroup is the API group of package metadata
+	metaAPIGroup = "meta.pkg.crossplane.io"
+)
+
+// Meta is the metadata of a package, read from its crossplane.yaml
+type Meta struct {
+	Kind config.PackageKind
+	Name string
+}
+
+// BuildOptions configures a package build
+type BuildOptions struct {
+	// RuntimeImage is embedded as the base of the package, as provider and
+	// function packages need their runtime in the same image
+	RuntimeImage string
+	// Ignore lists directories, relative to the package root, that are not
+	// part of the package, e.g. examples
+	Ignore []string
+}
+
+// Build builds a package image from a directory holding crossplane.yaml and
+// the package manifests, such as XRDs and Compositions
+func Build(dir string, opts BuildOptions) (v1.Image, *Meta, error) {
+	meta, err := ReadMeta(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stream, err := packageStream(dir, opts.Ignore)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return tarFile(StreamFile, stream)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create package layer: %v", err)
+	}
+
+	base := empty.Image
+	if opts.RuntimeImage != "" {
+		ref, err := name.ParseReference(opts.RuntimeImage)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid runtime image %s: %v", opts.RuntimeImage, err)
+		}
+		base, err = remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get runtime image %s: %v", opts.RuntimeImage, err)
+		}
+	}
+
+	img, err := mutate.Append(base, mutate.Addendum{
+		Layer:       layer,
+		Annotations: map[string]string{annotationKey: baseAnnotation},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to add package layer: %v", err)
+	}
+
+	// Crossplane also finds the package layer through a config label keyed by its digest
+	digest, err := layer.Digest()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get package layer digest: %v", err)
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get image config: %v", err)
+	}
+	cfg = cfg.DeepCopy()
+	if cfg.Config.Labels == nil {
+		cfg.Config.Labels = map[string]string{}
+	}
+	cfg.Config.Labels[annotationKey+":"+digest.String()] = baseAnnotation
+
+	img, err = mutate.ConfigFile(img, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to set image config: %v", err)
+	}
+
+	return img, meta, nil
+}
+
+// ReadMeta reads the package metadata from the crossplane.yaml of a package directory
+func ReadMeta(dir string) (*Meta, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, MetaFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package metadata: %v", err)
+	}
+
+	return parseMeta(raw)
+}
+
+// ImageMeta reads the package metadata from the package layer of an image
+func ImageMeta(img v1.Image) (*Meta, error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image manifest: %v", err)
+	}
+
+	for _, desc := range manifest.Layers {
+		if desc.Annotations[annotationKey] != baseAnnotation {
+			continue
+		}
+
+		layer, err := img.LayerByDigest(desc.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get package layer: %v", err)
+		}
+		rc, err := layer.Uncompressed()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read package layer: %v", err)
+		}
+		defer rc.Close()
+
+		tr := tar.NewReader(rc)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read package layer: %v", err)
+			}
+			if hdr.Name != StreamFile {
+				continue
+			}
+
+			// The metadata is the first document of the package stream
+			var first yaml.Node
+			if err := yaml.NewDecoder(tr).Decode(&first); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %v", StreamFile, err)
+			}
+			raw, err := yaml.Marshal(&first)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %v", StreamFile, err)
+			}
+			return parseMeta(raw)
+		}
+	}
+
+	return nil, fmt.Errorf("image has no package layer")
+}
+
+// parseMeta parses and validates package metadata
+func parseMeta(raw []byte) (*Meta, error) {
+	var obj struct {
+		APIVersion string `yaml:"apiVersion"`
+		Kind       string `yaml:"kind"`
+		Metadata   struct {
+			Name string `yaml:"name"`
+		} `yaml:"metadata"`
+	}
+	if err := yaml.Unmarshal(raw, &obj); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", MetaFile, err)
+	}
+
+	if !strings.HasPrefix(obj.APIVersion, metaAPIGroup+"/") {
+		return nil, fmt.Errorf("%s must have an apiVersion in the %s group, got %q", MetaFile, metaAPIGroup, obj.APIVersion)
+	}
+	kind, err := config.ParsePackageKind(obj.Kind)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", MetaFile, err)
+	}
+	if obj.Metadata.Name == "" {
+		return nil, fmt.Errorf("%s must set metadata.name", MetaFile)
+	}
+
+	return &Meta{Kind: kind, Name: obj.Metadata.Name}, nil
+}
+
+// packageStream concatenates crossplane.yaml and every other YAML file under
+// dir into a single multi-document stream, with crossplane.yaml first
+func packageStream(dir string, ignore []string) ([]byte, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if rel != "." && (strings.HasPrefix(d.Name(), ".") || ignored(rel, ignore)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		ext := filepath.Ext(path)
+		if rel != MetaFile && (ext == ".yaml" || ext == ".yml") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package directory: %v", err)
+	}
+	sort.Strings(files)
+
+	var buf bytes.Buffer
+	for _, path := range append([]string{filepath.Join(dir, MetaFile)}, files...) {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 {
+			continue
+		}
+		if buf.Len() > 0 && !bytes.HasPrefix(raw, []byte("---")) {
+			buf.WriteString("---\n")
+		}
+		buf.Write(raw)
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ignored reports whether a directory relative to the package root is ignored
+func ignored(rel string, ignore []string) bool {
+	for _, dir := range ignore {
+		if filepath.Clean(dir) == rel {
+			return true
+		}
+	}
+	return false
+}
+
+// tarFile returns a tar archive holding a single file
+func tarFile(name string, content []byte) (io.ReadCloser, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(content); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(&buf), nil
+}
+
+// Write saves a package image to an .xpkg archive
+func Write(img v1.Image, path string) error {
+	if err := tarball.WriteToFile(path, nil, img); err != nil {
+		return fmt.Errorf("failed to write package %s: %v", path, err)
+	}
+	return nil
+}
+
+// Load reads a package image from an .xpkg archive
+func Load(path string) (v1.Image, error) {
+	img, err := tarball.ImageFromPath(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package %s: %v", path, err)
+	}
+	return annotate(img)
+}
+
+// annotate restores the package layer annotation from the image config
+// labels, as archives do not keep layer annotations
+func annotate(img v1.Image) (v1.Image, error) {
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image config: %v", err)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image layers: %v", err)
+	}
+
+	addenda := make([]mutate.Addendum, 0, len(layers))
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get layer digest: %v", err)
+		}
+
+		addendum := mutate.Addendum{Layer: layer}
+		if value, ok := cfg.Config.Labels[annotationKey+":"+digest.String()]; ok {
+			addendum.Annotations = map[string]string{annotationKey: value}
+		}
+		addenda = append(addenda, addendum)
+	}
+
+	annotated, err := mutate.Append(empty.Image, addenda...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to annotate package layers: %v", err)
+	}
+
+	return mutate.ConfigFile(annotated, cfg)
+}
+
+// Push pushes a package image to a registry and returns its digest
+func Push(img v1.Image, ref string) (string, error) {
+	tag, err := name.ParseReference(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid package reference %s: %v", ref, err)
+	}
+
+	if err := remote.Write(tag, img, remote.WithAuthFromKeychain(authn.DefaultKeychain)); err != nil {
+		return "", fmt.Errorf("failed to push package %s: %v", ref, err)
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return "", fmt.Errorf("failed to get package digest: %v", err)
+	}
+
+	return digest.String(), nil
+}
@@ -0,0 +1,129 @@
+package xpkg
+
+import (
+	"archive/tar"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kanzifucius/crosslab/pkg/config"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+// writePackageDir writes a Configuration package directory
+func writePackageDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"crossplane.yaml":           "apiVersion: meta.pkg.crossplane.io/v1\nkind: Configuration\nmetadata:\n  name: platform-ref-test\n",
+		"apis/xrd.yaml":             "apiVersion: apiextensions.crossplane.io/v1\nkind: CompositeResourceDefinition\nmetadata:\n  name: xbuckets.example.org\n",
+		"apis/composition.yml":      "---\napiVersion: apiextensions.crossplane.io/v1\nkind: Composition\nmetadata:\n  name: xbuckets\n",
+		"examples/bucket.yaml":      "apiVersion: example.org/v1alpha1\nkind: Bucket\n",
+		".github/workflows/ci.yaml": "name: ci\n",
+		"README.md":                 "# platform-ref-test\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestBuild(t *testing.T) {
+	dir := writePackageDir(t)
+
+	img, meta, err := Build(dir, BuildOptions{Ignore: []string{"examples"}})
+	assert.NoError(t, err)
+	assert.Equal(t, &Meta{Kind: config.ConfigurationKind, Name: "platform-ref-test"}, meta)
+
+	manifest, err := img.Manifest()
+	assert.NoError(t, err)
+	assert.Len(t, manifest.Layers, 1)
+	assert.Equal(t, baseAnnotation, manifest.Layers[0].Annotations[annotationKey])
+
+	cfg, err := img.ConfigFile()
+	assert.NoError(t, err)
+	assert.Equal(t, baseAnnotation, cfg.Config.Labels[annotationKey+":"+manifest.Layers[0].Digest.String()])
+
+	layers, err := img.Layers()
+	assert.NoError(t, err)
+	rc, err := layers[0].Uncompressed()
+	assert.NoError(t, err)
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	hdr, err := tr.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, StreamFile, hdr.Name)
+	stream, err := io.ReadAll(tr)
+	assert.NoError(t, err)
+
+	docs := strings.Split(string(stream), "---\n")
+	assert.Len(t, docs, 3)
+	assert.Contains(t, docs[0], "kind: Configuration")
+	assert.Contains(t, docs[1], "kind: Composition")
+	assert.Contains(t, docs[2], "kind: CompositeResourceDefinition")
+	assert.NotContains(t, string(stream), "kind: Bucket")
+	assert.NotContains(t, string(stream), "name: ci")
+
+	t.Run("write and load", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "platform-ref-test"+Extension)
+		assert.NoError(t, Write(img, path))
+
+		loaded, err := Load(path)
+		assert.NoError(t, err)
+		want, _ := img.Digest()
+		got, _ := loaded.Digest()
+		assert.Equal(t, want, got)
+
+		loadedManifest, err := loaded.Manifest()
+		assert.NoError(t, err)
+		assert.Equal(t, baseAnnotation, loadedManifest.Layers[0].Annotations[annotationKey])
+
+		loadedMeta, err := ImageMeta(loaded)
+		assert.NoError(t, err)
+		assert.Equal(t, meta, loadedMeta)
+	})
+
+	t.Run("push", func(t *testing.T) {
+		server := httptest.NewServer(registry.New())
+		defer server.Close()
+
+		ref := strings.TrimPrefix(server.URL, "http://") + "/platform-ref-test:v0.1.0"
+		digest, err := Push(img, ref)
+		assert.NoError(t, err)
+		want, _ := img.Digest()
+		assert.Equal(t, want.String(), digest)
+	})
+}
+
+func TestReadMeta(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "wrong group", content: "apiVersion: pkg.crossplane.io/v1\nkind: Configuration\nmetadata: {name: a}\n", wantErr: "apiVersion"},
+		{name: "unknown kind", content: "apiVersion: meta.pkg.crossplane.io/v1\nkind: Composition\nmetadata: {name: a}\n", wantErr: "unknown package kind"},
+		{name: "missing name", content: "apiVersion: meta.pkg.crossplane.io/v1beta1\nkind: Function\n", wantErr: "metadata.name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, MetaFile), []byte(tt.content), 0644))
+
+			_, err := ReadMeta(dir)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	_, err := ReadMeta(t.TempDir())
+	assert.ErrorContains(t, err, "failed to read package metadata")
+}